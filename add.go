@@ -0,0 +1,62 @@
+package markov
+
+import "strings"
+
+// Build used to be the only door into the chain, which forced every
+// ingestion feature to be threaded through tokenization. Add is the
+// canonical lower-level entry point: it owns key canonicalization,
+// word interning, and count updates, so Build reduces to tokenization
+// plus shifting, and new ingestion paths (merges, incremental updates)
+// land on the same invariants.
+
+// addSettings collects what AddOptions can change about one Add call.
+type addSettings struct {
+	weight float64
+	meta   TokenMeta
+}
+
+// AddOption customizes one Add call.
+type AddOption func(*addSettings)
+
+// WithWeight replaces the integral count with an exact fractional
+// weight, for ingesting externally adjusted models.
+func WithWeight(w float64) AddOption {
+	return func(s *addSettings) { s.weight = w }
+}
+
+// WithTokenMeta attaches per-token context for the registered
+// collectors to observe.
+func WithTokenMeta(m TokenMeta) AddOption {
+	return func(s *addSettings) { s.meta = m }
+}
+
+// Add records that word followed the prefix count more times. The
+// prefix is canonicalized into the chain's key form (words joined with
+// spaces); calls with a non-positive effective weight are ignored.
+func (c *Chain) Add(prefix []string, word string, count int, opts ...AddOption) {
+	s := addSettings{weight: float64(count)}
+	for _, o := range opts {
+		o(&s)
+	}
+	if s.weight <= 0 {
+		return
+	}
+	c.addSuffix(strings.Join(prefix, " "), word, s.weight)
+	for _, col := range c.collectors {
+		col.Observe(prefix, word, s.meta)
+	}
+}
+
+// internWord returns a canonical instance of the word. Corpora repeat
+// words constantly; interning keeps one backing string per distinct
+// word instead of one per occurrence-containing map.
+func (c *Chain) internWord(w string) string {
+	if c.intern == nil {
+		c.intern = make(map[string]string)
+	}
+	if canonical, ok := c.intern[w]; ok {
+		return canonical
+	}
+	c.intern[w] = w
+	return w
+}
@@ -0,0 +1,63 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddMatchesBuild(t *testing.T) {
+	text := "the cat and the dog and the cat again"
+	built := NewChain(2)
+	built.Build(strings.NewReader(text))
+
+	// Reproducing Build by hand through Add: tokenization plus
+	// shifting is all Build adds on top of it.
+	added := NewChain(2)
+	p := Prefix{"\"\"", "\"\""}
+	for _, w := range strings.Fields(text) {
+		added.Add(p, w, 1)
+		p.Shift(w)
+	}
+	if !built.Equal(added) {
+		t.Errorf("Add-built chain %v differs from Build's %v", added.chain, built.chain)
+	}
+}
+
+func TestAddAccumulatesCounts(t *testing.T) {
+	c := NewChain(1)
+	c.Add([]string{"the"}, "cat", 2)
+	c.Add([]string{"the"}, "cat", 3)
+	if got := c.chain["the"]["cat"]; got != 5 {
+		t.Errorf("accumulated weight = %v, want 5", got)
+	}
+}
+
+func TestAddIgnoresNonPositiveCounts(t *testing.T) {
+	c := NewChain(1)
+	c.Add([]string{"the"}, "cat", 0)
+	c.Add([]string{"the"}, "dog", -3)
+	c.Add([]string{"the"}, "fox", 1, WithWeight(-0.5))
+	if len(c.chain) != 0 {
+		t.Errorf("non-positive adds created entries: %v", c.chain)
+	}
+}
+
+func TestAddWithWeight(t *testing.T) {
+	c := NewChain(1)
+	c.Add([]string{"the"}, "cat", 1, WithWeight(2.5))
+	if got := c.chain["the"]["cat"]; got != 2.5 {
+		t.Errorf("weight = %v, want 2.5", got)
+	}
+}
+
+func TestAddInternsWords(t *testing.T) {
+	c := NewChain(1)
+	// Distinct backing strings with equal content collapse to one
+	// interned instance.
+	c.Add([]string{"a"}, strings.Repeat("word", 1), 1)
+	c.Add([]string{"b"}, strings.Repeat("word", 1), 1)
+	c.Add([]string{"c"}, "other", 1)
+	if len(c.intern) != 2 {
+		t.Errorf("intern table holds %d words, want 2", len(c.intern))
+	}
+}
@@ -0,0 +1,127 @@
+package markov
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// analyze mode inspects a model rather than generating from it. Its
+// first report, -bottlenecks, finds the prefixes output keeps
+// funneling through: high estimated traffic but near-zero entropy, so
+// the continuation is forced.
+
+// entropy returns the Shannon entropy of the weight distribution, in
+// bits. A single-suffix prefix has entropy 0.
+func entropy(counts map[string]float64) float64 {
+	total := 0.0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	h := 0.0
+	for _, n := range counts {
+		p := n / total
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// incomingWeights builds the reverse index on demand: for every
+// transition key --suffix--> shifted key, the suffix's weight is
+// credited to the shifted key. Total incoming weight approximates how
+// often a walk visits the prefix (a stationary-ish estimate, not a
+// true stationary distribution).
+func (c *Chain) incomingWeights() map[string]float64 {
+	in := make(map[string]float64, len(c.chain))
+	for key, counts := range c.chain {
+		p := Prefix(strings.Split(key, " "))
+		for s, n := range counts {
+			q := make(Prefix, len(p))
+			copy(q, p)
+			q.Shift(s)
+			qk := q.String()
+			if _, ok := c.chain[qk]; ok {
+				in[qk] += n
+			}
+		}
+	}
+	return in
+}
+
+// bottleneck is one ranked row of the -bottlenecks report.
+type bottleneck struct {
+	prefix   string
+	incoming float64
+	entropy  float64
+	forced   string // the dominant continuation
+}
+
+// bottlenecks returns the top prefixes whose entropy is at most
+// maxEntropy, ranked by incoming weight.
+func (c *Chain) bottlenecks(top int, maxEntropy float64) []bottleneck {
+	in := c.incomingWeights()
+	var rows []bottleneck
+	for key, counts := range c.chain {
+		h := entropy(counts)
+		if h > maxEntropy || in[key] == 0 {
+			continue
+		}
+		best, bestN := "", 0.0
+		for s, n := range counts {
+			if n > bestN || (n == bestN && s < best) {
+				best, bestN = s, n
+			}
+		}
+		rows = append(rows, bottleneck{prefix: key, incoming: in[key], entropy: h, forced: best})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].incoming != rows[j].incoming {
+			return rows[i].incoming > rows[j].incoming
+		}
+		return rows[i].prefix < rows[j].prefix
+	})
+	if len(rows) > top {
+		rows = rows[:top]
+	}
+	return rows
+}
+
+// runAnalyze implements the analyze mode.
+func runAnalyze(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file to analyze")
+	bottlenecksFlag := fs.Bool("bottlenecks", false, "report high-traffic prefixes with forced continuations")
+	top := fs.Int("top", 10, "number of rows to report")
+	maxEntropy := fs.Float64("max-entropy", 0.5, "report only prefixes with at most this entropy (bits)")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" {
+		return &usageError{msg: "analyze mode requires -model"}
+	}
+	if !*bottlenecksFlag {
+		return &usageError{msg: "analyze currently requires -bottlenecks"}
+	}
+	if *top < 1 {
+		return &usageError{msg: "-top must be at least 1"}
+	}
+	c, err := loadModelFile(*model)
+	if err != nil {
+		return err
+	}
+	rows := c.bottlenecks(*top, *maxEntropy)
+	tw := tabwriter.NewWriter(stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PREFIX\tINCOMING\tENTROPY\tFORCED-NEXT")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%s\n", r.prefix, formatWeight(r.incoming), r.entropy, r.forced)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,99 @@
+package markov
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntropyKnownValues(t *testing.T) {
+	cases := []struct {
+		name   string
+		counts map[string]float64
+		want   float64
+	}{
+		{"forced", map[string]float64{"only": 7}, 0},
+		{"fair coin", map[string]float64{"a": 1, "b": 1}, 1},
+		{"uniform four", map[string]float64{"a": 2, "b": 2, "c": 2, "d": 2}, 2},
+	}
+	for _, tc := range cases {
+		if got := entropy(tc.counts); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("entropy(%s) = %g, want %g", tc.name, got, tc.want)
+		}
+	}
+}
+
+// funnelChain builds an order-1 chain where many prefixes all feed
+// into "hub", which has exactly one continuation — the classic
+// high-traffic forced transition — while "fork" gets the same traffic
+// but an even two-way split.
+func funnelChain() *Chain {
+	c := NewChain(1)
+	for _, src := range []string{"a", "b", "c", "d"} {
+		c.addSuffix(src, "hub", 5)
+	}
+	c.addSuffix("hub", "out", 1)
+	c.addSuffix("out", "fork", 20)
+	c.addSuffix("fork", "left", 3)
+	c.addSuffix("fork", "right", 3)
+	c.addSuffix("left", "a", 1)
+	c.addSuffix("right", "b", 1)
+	return c
+}
+
+func TestIncomingWeightsAggregateReverseEdges(t *testing.T) {
+	in := funnelChain().incomingWeights()
+	if in["hub"] != 20 {
+		t.Errorf("incoming weight of hub = %v, want 20", in["hub"])
+	}
+	if in["fork"] != 20 {
+		t.Errorf("incoming weight of fork = %v, want 20", in["fork"])
+	}
+}
+
+func TestBottlenecksRankForcedHighTrafficFirst(t *testing.T) {
+	rows := funnelChain().bottlenecks(3, 0.5)
+	if len(rows) == 0 {
+		t.Fatal("no bottlenecks found")
+	}
+	if rows[0].prefix != "hub" || rows[0].forced != "out" {
+		t.Errorf("top bottleneck = %+v, want hub forced to out", rows[0])
+	}
+	if rows[0].entropy != 0 {
+		t.Errorf("hub entropy = %v, want 0", rows[0].entropy)
+	}
+	// fork has the same traffic but 1 bit of entropy: above the cap.
+	for _, r := range rows {
+		if r.prefix == "fork" {
+			t.Error("fork (entropy 1) reported as a bottleneck")
+		}
+	}
+}
+
+func TestAnalyzeBottlenecksCLI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.model")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := funnelChain().writeModel(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	code, stdout, stderr := runCLI("analyze", "-model", path, "-bottlenecks", "-top", "2")
+	if code != 0 {
+		t.Fatalf("analyze exited %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if !strings.HasPrefix(lines[0], "PREFIX") {
+		t.Errorf("missing table header: %q", lines[0])
+	}
+	if len(lines) < 2 || !strings.Contains(lines[1], "hub") {
+		t.Errorf("top row does not name the hub:\n%s", stdout)
+	}
+	if code, _, _ := runCLI("analyze", "-model", path); code != exitUsage {
+		t.Errorf("analyze without -bottlenecks exited %d, want %d", code, exitUsage)
+	}
+}
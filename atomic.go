@@ -0,0 +1,34 @@
+package markov
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicWriteFile writes a file by streaming into a temp file created
+// in the destination's own directory and renaming it into place.
+// Writing in the destination directory (not os.TempDir) keeps the
+// rename on one volume, where it is atomic on every platform the CLI
+// runs on; a failed or interrupted write leaves the old file untouched.
+func atomicWriteFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return &notFoundError{what: "output", path: path, err: err}
+	}
+	name := tmp.Name()
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(name)
+		return &writeError{path: path, err: err}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(name)
+		return &writeError{path: path, err: err}
+	}
+	if err := os.Rename(name, path); err != nil {
+		os.Remove(name)
+		return &writeError{path: path, err: err}
+	}
+	return nil
+}
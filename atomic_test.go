@@ -0,0 +1,83 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "out.model")
+	err := atomicWriteFile(dest, func(w io.Writer) error {
+		// The temp file must live in the destination's directory, so
+		// the final rename never crosses a volume boundary.
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) != 1 || filepath.Ext(entries[0].Name()) == ".model" {
+			t.Errorf("mid-write directory holds %v, want one temp file", entries)
+		}
+		_, werr := fmt.Fprintln(w, "content")
+		return werr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || string(got) != "content\n" {
+		t.Errorf("destination holds %q, %v", got, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp droppings left behind: %v", entries)
+	}
+}
+
+func TestAtomicWriteFileFailureLeavesOldFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "out.model")
+	if err := os.WriteFile(dest, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := atomicWriteFile(dest, func(io.Writer) error { return errors.New("boom") })
+	var we *writeError
+	if !errors.As(err, &we) {
+		t.Fatalf("failed write returned %v, want a writeError", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "old" {
+		t.Errorf("failed write clobbered the old file: %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp droppings left behind: %v", entries)
+	}
+
+	// An unreachable destination directory is an output-not-found.
+	err = atomicWriteFile(filepath.Join(dir, "missing", "x"), func(io.Writer) error { return nil })
+	var nf *notFoundError
+	if !errors.As(err, &nf) {
+		t.Errorf("unreachable destination returned %v, want a notFoundError", err)
+	}
+}
+
+func TestSkipMatcherIsSeparatorAgnostic(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{`node_modules\**`, "node_modules/pkg/x.js", true},
+		{"node_modules/**", `node_modules\pkg\x.js`, true},
+		{`docs\*.md`, `docs\readme.md`, true},
+		{`docs\*.md`, "docs/sub/readme.md", false},
+	}
+	for _, tc := range cases {
+		if got := matchSkipPattern(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchSkipPattern(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package markov
+
+import (
+	"sort"
+	"strings"
+)
+
+// With a long prefix on a modest corpus, generation routinely hits a
+// window that appeared exactly once at the end of the input and has no
+// suffixes, stopping far short of the word target. Backoff keeps the
+// walk going: when the full window has no continuations it retries on
+// the last prefixLen-1 words, then prefixLen-2, and so on, falling
+// back to a random restart only when every order misses. The
+// lower-order tables are derived from the chain itself, so loaded
+// models back off the same way freshly built ones do.
+
+// backoffIndex holds suffix counts aggregated by the trailing words of
+// each key: orders[L] maps the last L words of every key to the merged
+// counts of all keys sharing that tail.
+type backoffIndex struct {
+	orders []map[string]map[string]float64
+}
+
+// ensureBackoff derives the lower-order tables on first use. The index
+// is a pure function of the chain, which never changes during
+// generation, so deriving once is enough.
+func (c *Chain) ensureBackoff() {
+	if c.backoff != nil || c.prefixLen < 2 {
+		return
+	}
+	idx := &backoffIndex{orders: make([]map[string]map[string]float64, c.prefixLen)}
+	for l := 1; l < c.prefixLen; l++ {
+		idx.orders[l] = make(map[string]map[string]float64)
+	}
+	for key, counts := range c.chain {
+		words := strings.Split(key, " ")
+		for l := 1; l < c.prefixLen && l < len(words); l++ {
+			tail := strings.Join(words[len(words)-l:], " ")
+			m := idx.orders[l][tail]
+			if m == nil {
+				m = make(map[string]float64)
+				idx.orders[l][tail] = m
+			}
+			for w, n := range counts {
+				m[w] += n
+			}
+		}
+	}
+	c.backoff = idx
+}
+
+// backoffChoices walks the ladder from the longest partial window down
+// to single words and returns the first table with continuations, or
+// nil when every order misses.
+func (c *Chain) backoffChoices(p Prefix) map[string]float64 {
+	c.ensureBackoff()
+	if c.backoff == nil {
+		return nil
+	}
+	for l := c.prefixLen - 1; l >= 1; l-- {
+		tail := strings.Join(p[len(p)-l:], " ")
+		if m := c.backoff.orders[l][tail]; len(m) > 0 {
+			return m
+		}
+	}
+	return nil
+}
+
+// restartRandom points the prefix window at a uniformly chosen known
+// key. Keys are visited in sorted order so seeded runs restart at the
+// same place.
+func (c *Chain) restartRandom(p Prefix) {
+	if len(c.chain) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(c.chain))
+	for k := range c.chain {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	copy(p, strings.Split(keys[randIntn(c.rng, len(keys))], " "))
+}
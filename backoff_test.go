@@ -0,0 +1,75 @@
+package markov
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// deadEndChain is an order-3 chain over a single short sentence: the
+// final window has no suffixes, so a plain walk stops within one pass.
+func deadEndChain() *Chain {
+	c := NewChain(3)
+	c.Build(strings.NewReader("the quick brown fox jumps over the lazy dog"))
+	return c
+}
+
+func TestBackoffReachesWordTarget(t *testing.T) {
+	rand.Seed(1)
+	plain := deadEndChain().GenerateWithOptions(GenerateOptions{Words: 30})
+	if plain.Words >= 30 {
+		t.Fatalf("plain walk produced %d words; the corpus no longer dead-ends", plain.Words)
+	}
+	rand.Seed(1)
+	res := deadEndChain().GenerateWithOptions(GenerateOptions{Words: 30, MaxSteps: 1000, Backoff: true})
+	if res.Words != 30 {
+		t.Errorf("backoff walk produced %d words, want 30 (text %q)", res.Words, res.Text)
+	}
+}
+
+func TestBackoffLadderPrefersLowerOrders(t *testing.T) {
+	// Only the full key "aa bb" exists, but its one-word tail "bb"
+	// must answer for any window ending in "bb" before a restart is
+	// considered.
+	c := NewChain(2)
+	c.addSuffix("aa bb", "cc", 1)
+	if got := c.backoffChoices(Prefix{"zz", "bb"}); got["cc"] != 1 {
+		t.Errorf("backoffChoices on a matching tail = %v, want cc:1", got)
+	}
+	if got := c.backoffChoices(Prefix{"zz", "qq"}); got != nil {
+		t.Errorf("backoffChoices on a miss = %v, want nil", got)
+	}
+}
+
+func TestBackoffAggregatesSharedTails(t *testing.T) {
+	// Two keys ending in "bb" merge their counts at order one.
+	c := NewChain(2)
+	c.addSuffix("aa bb", "cc", 2)
+	c.addSuffix("dd bb", "cc", 1)
+	c.addSuffix("dd bb", "ee", 3)
+	got := c.backoffChoices(Prefix{"zz", "bb"})
+	if got["cc"] != 3 || got["ee"] != 3 {
+		t.Errorf("merged tail counts = %v, want cc:3 ee:3", got)
+	}
+}
+
+func TestBackoffFlag(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("the quick brown fox jumps over the lazy dog"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "3", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "30", "-backoff", "-seed", "7")
+	if code != 0 {
+		t.Fatalf("generate -backoff exited %d: %s", code, stderr)
+	}
+	if got := len(strings.Fields(stdout)); got != 30 {
+		t.Errorf("generated %d words with -backoff, want 30: %q", got, stdout)
+	}
+}
@@ -0,0 +1,166 @@
+package markov
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The binary model format exists for load speed: a big text model
+// spends seconds in the line-by-line parser, while a gob of the
+// chain's maps decodes in one pass and gzip keeps it small on disk.
+// Layout: the magic (8 bytes), a format version byte, then one gzip
+// stream holding a single gob-encoded modelPayload — the schema the
+// JSON format shares, so the formats cannot drift apart.
+
+// binMagic opens every binary model file.
+var binMagic = [8]byte{'M', 'A', 'R', 'K', 'G', 'O', 'B', '\n'}
+
+// binVersion is bumped when the payload schema changes incompatibly.
+const binVersion = 1
+
+// binPayload is the gob schema. gob decodes nested maps (and slices of
+// slices) through reflection one element at a time, which made a naive
+// encoding of the shared payload slower to load than the text parser;
+// on the wire the chain flattens into parallel scalar slices, grouped
+// by key, which is what makes the format fast. Meta travels with its
+// Chain field nil.
+type binPayload struct {
+	Meta    modelPayload
+	Keys    []string
+	KeyLens []int     // suffixes per key, parallel to Keys
+	Words   []string  // every suffix, grouped by key
+	Counts  []float64 // parallel to Words
+}
+
+// flatten converts the payload's chain into the slice form.
+func flatten(m modelPayload) binPayload {
+	b := binPayload{
+		Keys:    make([]string, 0, len(m.Chain)),
+		KeyLens: make([]int, 0, len(m.Chain)),
+	}
+	for key, counts := range m.Chain {
+		b.Keys = append(b.Keys, key)
+		b.KeyLens = append(b.KeyLens, len(counts))
+		for w, n := range counts {
+			b.Words = append(b.Words, w)
+			b.Counts = append(b.Counts, n)
+		}
+	}
+	m.Chain = nil
+	b.Meta = m
+	return b
+}
+
+// unflatten rebuilds a chain, inserting each key's suffix map in one
+// pre-sized allocation instead of detouring through the shared
+// payload's nested maps.
+func (b binPayload) unflatten() (*Chain, error) {
+	if len(b.KeyLens) != len(b.Keys) || len(b.Counts) != len(b.Words) {
+		return nil, fmt.Errorf("binary model: inconsistent section lengths (%d keys, %d lengths, %d suffixes, %d counts)", len(b.Keys), len(b.KeyLens), len(b.Words), len(b.Counts))
+	}
+	c, err := b.Meta.headerChain()
+	if err != nil {
+		return nil, err
+	}
+	next := 0
+	for i, key := range b.Keys {
+		n := b.KeyLens[i]
+		if n < 0 || next+n > len(b.Words) {
+			return nil, fmt.Errorf("binary model: key %q claims %d suffixes but only %d remain", key, n, len(b.Words)-next)
+		}
+		words, ns := b.Words[next:next+n], b.Counts[next:next+n]
+		next += n
+		counts := make(map[string]float64, n)
+		for j, w := range words {
+			if err := validWeight(ns[j]); err != nil {
+				return nil, fmt.Errorf("binary model: %v for suffix %q of prefix %q", err, w, key)
+			}
+			counts[c.internWord(w)] += ns[j]
+		}
+		if _, dup := c.chain[key]; dup {
+			// A duplicated key (never written by Save) accumulates, the
+			// way every other loader treats repeated lines.
+			for w, weight := range counts {
+				c.addSuffix(key, w, weight)
+			}
+			continue
+		}
+		if c.reverse != nil {
+			// The fast path below skips addSuffix, so the reverse index
+			// is fed directly.
+			for w, weight := range counts {
+				c.noteReverse(key, w, weight)
+			}
+		}
+		c.chain[key] = counts
+	}
+	if next != len(b.Words) {
+		return nil, fmt.Errorf("binary model: %d trailing suffixes not claimed by any key", len(b.Words)-next)
+	}
+	if len(c.chain) == 0 {
+		return nil, ErrEmptyChain
+	}
+	return c, nil
+}
+
+// Save writes the chain in the binary model format.
+func (c *Chain) Save(w io.Writer) error {
+	if _, err := w.Write(binMagic[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{binVersion}); err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	if err := gob.NewEncoder(gz).Encode(flatten(c.payload())); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// LoadChain reads a chain written by Save. A text or JSON model is
+// refused with an error pointing at the right loader rather than a
+// bare gob decode failure.
+func LoadChain(r io.Reader) (*Chain, error) {
+	var head [9]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("binary model: reading header: %v", err)
+	}
+	if !bytes.Equal(head[:8], binMagic[:]) {
+		if b := head[0]; b >= '0' && b <= '9' || b == '{' {
+			return nil, fmt.Errorf("this looks like a text or JSON model, not a binary one — load it with ReadModel, or let the CLI pick by content")
+		}
+		return nil, fmt.Errorf("not a binary model: bad magic %q", head[:8])
+	}
+	if head[8] != binVersion {
+		return nil, fmt.Errorf("unsupported binary model version %d (this build reads version %d)", head[8], binVersion)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("binary model: %v", err)
+	}
+	defer gz.Close()
+	var b binPayload
+	if err := gob.NewDecoder(gz).Decode(&b); err != nil {
+		return nil, fmt.Errorf("binary model: %v", err)
+	}
+	return b.unflatten()
+}
+
+// isBinModel reports whether the file starts with the binary magic.
+func isBinModel(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var head [8]byte
+	if _, err := io.ReadFull(f, head[:]); err != nil {
+		return false
+	}
+	return head == binMagic
+}
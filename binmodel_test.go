@@ -0,0 +1,122 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	c := maximalChain(t)
+	loaded := reload(t, c, c.Save, LoadChain)
+	if !c.Equal(loaded) {
+		t.Error("binary round trip lost data")
+	}
+}
+
+func TestLoadChainRejectsTextModel(t *testing.T) {
+	c := NewChain(1)
+	c.Build(strings.NewReader("a b a c"))
+	var buf bytes.Buffer
+	if err := c.writeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadChain(&buf)
+	if err == nil || !strings.Contains(err.Error(), "text") {
+		t.Errorf("LoadChain on a text model returned %v, want an error naming the text format", err)
+	}
+}
+
+func TestLoadChainRejectsFutureVersion(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(binMagic[:])
+	buf.WriteByte(binVersion + 1)
+	_, err := LoadChain(&buf)
+	if err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("LoadChain accepted version %d: %v", binVersion+1, err)
+	}
+}
+
+func TestBinaryFormatCLI(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("tick tock ", 30)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.bin")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-format", "bin", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if !isBinModel(model) {
+		t.Fatal("written model does not carry the binary magic")
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "10")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output from the binary model")
+	}
+	// And feeding the model back to read mode is refused like the other
+	// model formats.
+	if code, _, stderr := runCLI("read", "-out", filepath.Join(dir, "m2"), model); code != exitUsage || !strings.Contains(stderr, "binary model") {
+		t.Errorf("read on a binary model exited %d (%s), want a usage redirect", code, stderr)
+	}
+}
+
+// benchmarkLoadChain is a chain big enough that parse speed matters:
+// a few thousand distinct prefixes over a 200-word vocabulary.
+func benchmarkLoadChain(b *testing.B) *Chain {
+	b.Helper()
+	rng := rand.New(rand.NewSource(1))
+	vocab := make([]string, 200)
+	for i := range vocab {
+		vocab[i] = fmt.Sprintf("w%03d", i)
+	}
+	var sb strings.Builder
+	for i := 0; i < 50000; i++ {
+		sb.WriteString(vocab[rng.Intn(len(vocab))])
+		sb.WriteByte(' ')
+	}
+	c := NewChain(2)
+	if err := c.Build(strings.NewReader(sb.String())); err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+func BenchmarkLoadTextModel(b *testing.B) {
+	c := benchmarkLoadChain(b)
+	var buf bytes.Buffer
+	if err := c.writeModel(&buf); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := readModelStream(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadBinaryModel(b *testing.B) {
+	c := benchmarkLoadChain(b)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadChain(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// BuildErrorCategory classifies where in the pipeline an input failed,
+// so callers can tell a missing file from a file we could open but not
+// make sense of.
+type BuildErrorCategory string
+
+const (
+	BuildErrOpen   BuildErrorCategory = "open"   // the input could not be opened
+	BuildErrRead   BuildErrorCategory = "read"   // an I/O error interrupted streaming
+	BuildErrDecode BuildErrorCategory = "decode" // the bytes are not text we can tokenize
+	BuildErrParse  BuildErrorCategory = "parse"  // structured input did not parse
+)
+
+// BuildError is one input's failure.
+type BuildError struct {
+	Input    string
+	Category BuildErrorCategory
+	Err      error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Input, e.Category, e.Err)
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// buildErrorDetailSample bounds how many per-input details the
+// summarized Error message carries; the full list stays in Errs.
+const buildErrorDetailSample = 3
+
+// BuildErrors aggregates per-input failures from a multi-input build.
+// It implements error with a summarized message; callers that want the
+// full list unwrap it with errors.As.
+type BuildErrors struct {
+	Errs []BuildError
+
+	// attempted counts the files the build tried (after directory
+	// expansion), so callers can tell "some failed" from "all failed".
+	attempted int
+}
+
+func (e *BuildErrors) add(input string, cat BuildErrorCategory, err error) {
+	e.Errs = append(e.Errs, BuildError{Input: input, Category: cat, Err: err})
+}
+
+func (e *BuildErrors) Error() string {
+	byCat := make(map[BuildErrorCategory]int)
+	for _, be := range e.Errs {
+		byCat[be.Category]++
+	}
+	var cats []string
+	for _, cat := range []BuildErrorCategory{BuildErrOpen, BuildErrRead, BuildErrDecode, BuildErrParse} {
+		if n := byCat[cat]; n > 0 {
+			cats = append(cats, fmt.Sprintf("%s: %d", cat, n))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d input(s) failed (%s)", len(e.Errs), strings.Join(cats, ", "))
+	for i, be := range e.Errs {
+		if i == buildErrorDetailSample {
+			fmt.Fprintf(&b, "; and %d more", len(e.Errs)-i)
+			break
+		}
+		fmt.Fprintf(&b, "; %s: %v", be.Input, be.Err)
+	}
+	return b.String()
+}
+
+// exitCode maps the aggregate onto the CLI convention: inputs we could
+// not reach are "not found", inputs whose contents were wrong are
+// "corrupt".
+func (e *BuildErrors) exitCode() int {
+	for _, be := range e.Errs {
+		if be.Category == BuildErrDecode || be.Category == BuildErrParse {
+			return exitCorrupt
+		}
+	}
+	return exitNotFound
+}
+
+// renderTable writes the per-input failures as an aligned table.
+func (e *BuildErrors) renderTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INPUT\tCATEGORY\tERROR")
+	for _, be := range e.Errs {
+		fmt.Fprintf(tw, "%s\t%s\t%v\n", be.Input, be.Category, be.Err)
+	}
+	tw.Flush()
+}
+
+// errTrackReader remembers the first non-EOF error a read returned,
+// because the tokenizer itself swallows read errors to end the stream.
+type errTrackReader struct {
+	r   io.Reader
+	err error
+}
+
+func (t *errTrackReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF && t.err == nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// BuildFromFiles trains the chain from each named file, resetting the
+// rolling prefix between files (or carrying it over with continuous).
+// It keeps going past failed inputs, collecting them into the returned
+// BuildErrors (nil when every input was ingested); with failFast it
+// stops at the first failure instead. It is the plain-files form of
+// BuildFromInputs.
+func (c *Chain) BuildFromFiles(inputs []string, continuous, failFast bool) *BuildErrors {
+	return c.BuildFromInputs(inputs, IngestOptions{Continuous: continuous, FailFast: failFast})
+}
+
+// buildFromFile ingests one file, running tokens through clean when one
+// is given and classifying any failure.
+func (c *Chain) buildFromFile(name string, clean func(string) string, continuous bool, carry *Prefix) error {
+	fi, err := os.Open(name)
+	if err != nil {
+		return &BuildError{Input: name, Category: BuildErrOpen, Err: err}
+	}
+	defer fi.Close()
+
+	// A NUL byte in the first block is the classic binary-file
+	// signature; tokenizing such input only produces garbage keys.
+	head := make([]byte, 512)
+	n, err := fi.Read(head)
+	if err != nil && err != io.EOF {
+		return &BuildError{Input: name, Category: BuildErrRead, Err: err}
+	}
+	head = head[:n]
+	if i := bytes.IndexByte(head, 0); i >= 0 {
+		return &BuildError{Input: name, Category: BuildErrDecode, Err: fmt.Errorf("input looks binary (NUL byte at offset %d)", i)}
+	}
+
+	tr := &errTrackReader{r: io.MultiReader(bytes.NewReader(head), fi)}
+	var buildErr error
+	if continuous {
+		*carry, buildErr = c.buildClean(tr, clean, *carry)
+	} else {
+		_, buildErr = c.buildClean(tr, clean, nil)
+	}
+	if tr.err != nil {
+		return &BuildError{Input: name, Category: BuildErrRead, Err: tr.err}
+	}
+	// The tokenizer's own errors (an over-long line, say) never pass
+	// through the reader, so they are classified here.
+	if buildErr != nil {
+		return &BuildError{Input: name, Category: BuildErrRead, Err: buildErr}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mixedInputs lays out one good input and three bad ones: a missing
+// file (open), an empty directory (walked into zero files, not an
+// error), and a binary file (decode).
+func mixedInputs(t *testing.T) (good string, bad []string) {
+	t.Helper()
+	dir := t.TempDir()
+	good = filepath.Join(dir, "good.txt")
+	if err := os.WriteFile(good, []byte(strings.Repeat("tick tock ", 20)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	subdir := filepath.Join(dir, "adir")
+	if err := os.Mkdir(subdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "blob.bin")
+	if err := os.WriteFile(binary, []byte("abc\x00def"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return good, []string{filepath.Join(dir, "missing.txt"), subdir, binary}
+}
+
+func TestBuildFromFilesAggregatesCategories(t *testing.T) {
+	good, bad := mixedInputs(t)
+	c := NewChain(1)
+	buildErrs := c.BuildFromFiles(append([]string{good}, bad...), false, false)
+	if buildErrs == nil {
+		t.Fatal("two bad inputs produced no BuildErrors")
+	}
+	if len(buildErrs.Errs) != 2 {
+		t.Fatalf("aggregate holds %d errors, want 2: %v", len(buildErrs.Errs), buildErrs)
+	}
+	want := []BuildErrorCategory{BuildErrOpen, BuildErrDecode}
+	for i, be := range buildErrs.Errs {
+		if be.Category != want[i] {
+			t.Errorf("error %d (%s) categorized %s, want %s", i, be.Input, be.Category, want[i])
+		}
+	}
+	// The good input must still have been ingested.
+	if len(c.chain) == 0 {
+		t.Error("good input was not ingested alongside the failures")
+	}
+	// The aggregate unwraps via errors.As through added context.
+	wrapped := fmt.Errorf("build: %w", error(buildErrs))
+	var got *BuildErrors
+	if !errors.As(wrapped, &got) || len(got.Errs) != 2 {
+		t.Error("errors.As does not recover the full list from a wrapped aggregate")
+	}
+	msg := buildErrs.Error()
+	for _, frag := range []string{"2 input(s) failed", "open: 1", "decode: 1"} {
+		if !strings.Contains(msg, frag) {
+			t.Errorf("summary %q is missing %q", msg, frag)
+		}
+	}
+}
+
+func TestBuildErrorsSummaryIsBounded(t *testing.T) {
+	var e BuildErrors
+	for i := 0; i < 10; i++ {
+		e.add(fmt.Sprintf("in%d.txt", i), BuildErrOpen, errors.New("nope"))
+	}
+	msg := e.Error()
+	if !strings.Contains(msg, "and 7 more") {
+		t.Errorf("summary %q does not bound the detail sample", msg)
+	}
+}
+
+func TestBuildFromFilesFailFastStopsEarly(t *testing.T) {
+	good, bad := mixedInputs(t)
+	c := NewChain(1)
+	buildErrs := c.BuildFromFiles(append(bad, good), false, true)
+	if buildErrs == nil || len(buildErrs.Errs) != 1 {
+		t.Fatalf("fail-fast collected %v, want exactly the first failure", buildErrs)
+	}
+	if buildErrs.Errs[0].Category != BuildErrOpen {
+		t.Errorf("first failure categorized %s, want %s", buildErrs.Errs[0].Category, BuildErrOpen)
+	}
+}
+
+func TestReadRendersFailureTableAndExitCodes(t *testing.T) {
+	good, bad := mixedInputs(t)
+	out := filepath.Join(t.TempDir(), "m.model")
+	args := append([]string{"read", "-prefix", "1", "-out", out, good}, bad...)
+
+	// Default: warn with the table, keep the good input's model.
+	code, _, stderr := runCLI(args...)
+	if code != 0 {
+		t.Fatalf("read without -strict exited %d: %s", code, stderr)
+	}
+	for _, frag := range []string{"INPUT", "CATEGORY", "missing.txt", "decode"} {
+		if !strings.Contains(stderr, frag) {
+			t.Errorf("failure table %q is missing %q", stderr, frag)
+		}
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("model was not written despite the good input: %v", err)
+	}
+
+	// -strict: the decode failure governs the exit code.
+	if code, _, _ := runCLI(append([]string{"read", "-strict", "-prefix", "1", "-out", out, good}, bad...)...); code != exitCorrupt {
+		t.Errorf("read -strict exited %d, want %d", code, exitCorrupt)
+	}
+
+	// -fail-fast on an open failure maps to not-found.
+	if code, _, _ := runCLI("read", "-fail-fast", "-prefix", "1", "-out", out, bad[0], good); code != exitNotFound {
+		t.Errorf("read -fail-fast exited %d, want %d", code, exitNotFound)
+	}
+
+	// Losing every input fails even without -strict.
+	if code, _, _ := runCLI(append([]string{"read", "-prefix", "1", "-out", out}, bad...)...); code == 0 {
+		t.Error("read succeeded with zero ingested inputs")
+	}
+}
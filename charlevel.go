@@ -0,0 +1,47 @@
+package markov
+
+import "strings"
+
+// Word-level chains are too coarse for fake product names and
+// password-style strings. Character level makes every rune a token:
+// the prefix becomes a window of prefixLen runes and Generate
+// concatenates runes back into a string. Splitting is by rune, never
+// by byte, so multi-byte UTF-8 text survives intact. The level is
+// recorded in the model header, so generate mode cannot mistake a
+// character model for a word model.
+
+// SetCharLevel switches the chain to character level: Build feeds each
+// rune of its (whitespace-separated) tokens into the window, and the
+// joiner is cleared so generated runes concatenate. The other
+// normalizations still apply first; punctuation splitting becomes a
+// no-op since every rune already stands alone.
+func (c *Chain) SetCharLevel(on bool) {
+	c.charLevel = on
+	if on {
+		c.joinWith = ""
+	}
+}
+
+// eachRune hands fn one token per rune of tok, in order.
+func eachRune(tok string, fn func(token string)) {
+	for _, r := range tok {
+		fn(string(r))
+	}
+}
+
+// startTokens splits a start phrase the way Build tokenized the
+// training text: whitespace-separated words normally, one token per
+// rune at character level (whitespace is still never a token). A
+// char-level phrase split into words would shift whole words into a
+// window keyed by single runes and never match.
+func (c *Chain) startTokens(phrase string) []string {
+	fields := strings.Fields(phrase)
+	if !c.charLevel {
+		return fields
+	}
+	var tokens []string
+	for _, f := range fields {
+		eachRune(f, func(tok string) { tokens = append(tokens, tok) })
+	}
+	return tokens
+}
@@ -0,0 +1,111 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCharLevelBuildsRuneWindows(t *testing.T) {
+	c := NewChain(2)
+	c.SetCharLevel(true)
+	c.Build(strings.NewReader("abcabd"))
+	suffixes := c.chain["a b"]
+	if suffixes["c"] != 1 || suffixes["d"] != 1 {
+		t.Errorf(`window "a b" has suffixes %v, want one c and one d`, suffixes)
+	}
+}
+
+func TestCharLevelKeepsRunesWhole(t *testing.T) {
+	// Multi-byte runes: a byte-level splitter would shear these
+	// characters apart and leave invalid UTF-8 in the chain.
+	c := NewChain(1)
+	c.SetCharLevel(true)
+	c.Build(strings.NewReader("你好世界 你好朋友"))
+	if c.chain["你"]["好"] != 2 {
+		t.Fatalf(`chain %v lost the 你→好 transition`, c.chain)
+	}
+	for key, suffixes := range c.chain {
+		if !utf8.ValidString(key) {
+			t.Errorf("key %q is not valid UTF-8", key)
+		}
+		for w := range suffixes {
+			if !utf8.ValidString(w) {
+				t.Errorf("suffix %q is not valid UTF-8", w)
+			}
+		}
+	}
+	out := c.Generate(6)
+	if !utf8.ValidString(out) {
+		t.Errorf("generated %q is not valid UTF-8", out)
+	}
+	if strings.ContainsRune(out, utf8.RuneError) {
+		t.Errorf("generated %q contains the replacement character", out)
+	}
+}
+
+func TestCharLevelConcatenatesWithoutSpaces(t *testing.T) {
+	// The two-rune cycle has a single path, so the output is exact.
+	c := NewChain(1)
+	c.SetCharLevel(true)
+	c.Build(strings.NewReader("ababab"))
+	if got := c.Generate(4); got != "abab" {
+		t.Errorf("generated %q, want the runes concatenated as abab", got)
+	}
+}
+
+func TestCharLevelStartPhraseSeedsWindow(t *testing.T) {
+	// A start phrase on a char model used to be split into words, so
+	// the whole phrase landed in one slot of the rune window, never
+	// matched a key, and the walk silently restarted at random. Split
+	// into runes the seeded window has a single path, so the
+	// continuation is exact.
+	c := NewChain(2)
+	c.SetCharLevel(true)
+	c.Build(strings.NewReader("ababab"))
+	res := c.GenerateWithOptions(GenerateOptions{Words: 4, Start: "ab"})
+	if res.Text != "abab" {
+		t.Errorf(`continuation of "ab" = %q, want "abab"`, res.Text)
+	}
+}
+
+func TestCharModeSurvivesReload(t *testing.T) {
+	c := NewChain(2)
+	c.SetCharLevel(true)
+	c.Build(strings.NewReader("你好世界你好朋友"))
+	loaded := reload(t, c, c.writeModel, readModelStream)
+	if !loaded.charLevel {
+		t.Error("reload dropped the char level")
+	}
+	if !c.Equal(loaded) {
+		t.Error("char-level chain lost data in the text round trip")
+	}
+	if out := loaded.Generate(4); !utf8.ValidString(out) || out == "" {
+		t.Errorf("loaded model generated %q", out)
+	}
+}
+
+func TestCharModeFlag(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("你好你好你好"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.txt")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-mode", "char", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-seed", "1", "-words", "6")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	got := strings.TrimSpace(stdout)
+	if !utf8.ValidString(got) || strings.Contains(got, " ") {
+		t.Errorf("char-mode output %q should be unspaced valid UTF-8", got)
+	}
+	if utf8.RuneCountInString(got) != 6 {
+		t.Errorf("got %d runes %q, want 6", utf8.RuneCountInString(got), got)
+	}
+}
@@ -0,0 +1,15 @@
+// Command mark is the command-line front end for the markov package:
+// it trains Markov chain models on text corpora and generates text
+// from them. All of the behavior lives in the library; this binary
+// only hands over the arguments and the standard streams.
+package main
+
+import (
+	"os"
+
+	markov "github.com/yuxuanwu17/02601-hw1-mark"
+)
+
+func main() {
+	os.Exit(markov.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
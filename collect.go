@@ -0,0 +1,149 @@
+package markov
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Build-time statistics (sentence lengths, terminator sets, count of
+// counts, and whatever comes next) each need bookkeeping per token, and
+// a separate pass over a huge corpus per statistic is wasteful. A
+// Collector observes every token as it enters the chain through Add,
+// so any number of enabled statistics share the one build pass; each
+// serializes its result into a named stats section the model formats
+// carry alongside the transitions.
+
+// TokenMeta carries the per-token context the Add path knows for free.
+type TokenMeta struct {
+	Index int // zero-based position of the token within its input
+}
+
+// Collector accumulates one statistic over the build's token stream.
+type Collector interface {
+	// Name is the stats-section name, also the -collect flag name.
+	Name() string
+	// Observe is called once per token entering the chain.
+	Observe(prefix []string, word string, meta TokenMeta)
+	// Section serializes the accumulated statistic for the model file.
+	Section() string
+}
+
+// collectorFactories is the registry of collectors -collect can
+// enable, by section name.
+var collectorFactories = map[string]func() Collector{
+	"sentence-lengths": func() Collector { return &sentenceLengthCollector{hist: make(map[string]int)} },
+	"terminators":      func() Collector { return &terminatorCollector{seen: make(map[string]int)} },
+	"count-of-counts":  func() Collector { return &countOfCountsCollector{counts: make(map[string]int)} },
+}
+
+// parseCollectors instantiates the comma-separated collector names.
+func parseCollectors(s string) ([]Collector, error) {
+	var cols []Collector
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		factory, ok := collectorFactories[name]
+		if !ok {
+			known := make([]string, 0, len(collectorFactories))
+			for n := range collectorFactories {
+				known = append(known, n)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown collector %q (want one of %s)", name, strings.Join(known, ", "))
+		}
+		cols = append(cols, factory())
+	}
+	return cols, nil
+}
+
+// terminalToken reports whether the token ends a sentence.
+func terminalToken(word string) bool {
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?")
+}
+
+// sentenceLengthCollector histograms words per sentence, a sentence
+// ending at each terminal token.
+type sentenceLengthCollector struct {
+	hist   map[string]int
+	length int
+}
+
+func (s *sentenceLengthCollector) Name() string { return "sentence-lengths" }
+
+func (s *sentenceLengthCollector) Observe(prefix []string, word string, meta TokenMeta) {
+	s.length++
+	if terminalToken(word) {
+		s.hist[strconv.Itoa(s.length)]++
+		s.length = 0
+	}
+}
+
+func (s *sentenceLengthCollector) Section() string { return marshalSection(s.hist) }
+
+// terminatorCollector counts the distinct tokens that end sentences.
+type terminatorCollector struct {
+	seen map[string]int
+}
+
+func (t *terminatorCollector) Name() string { return "terminators" }
+
+func (t *terminatorCollector) Observe(prefix []string, word string, meta TokenMeta) {
+	if terminalToken(word) {
+		t.seen[word]++
+	}
+}
+
+func (t *terminatorCollector) Section() string { return marshalSection(t.seen) }
+
+// countOfCountsCollector histograms how many distinct words occur each
+// number of times, the input to Good-Turing style smoothing.
+type countOfCountsCollector struct {
+	counts map[string]int
+}
+
+func (c *countOfCountsCollector) Name() string { return "count-of-counts" }
+
+func (c *countOfCountsCollector) Observe(prefix []string, word string, meta TokenMeta) {
+	c.counts[word]++
+}
+
+func (c *countOfCountsCollector) Section() string {
+	hist := make(map[string]int)
+	for _, n := range c.counts {
+		hist[strconv.Itoa(n)]++
+	}
+	return marshalSection(hist)
+}
+
+// marshalSection serializes a histogram deterministically (JSON object
+// keys are emitted sorted).
+func marshalSection(m map[string]int) string {
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// AddCollector registers a collector to observe every token the next
+// builds add.
+func (c *Chain) AddCollector(col Collector) {
+	c.collectors = append(c.collectors, col)
+}
+
+// finishCollectors moves each collector's serialized section into the
+// chain's stats, where the model writers pick them up.
+func (c *Chain) finishCollectors() {
+	for _, col := range c.collectors {
+		c.setStat(col.Name(), col.Section())
+	}
+}
+
+// setStat records one named stats section.
+func (c *Chain) setStat(name, value string) {
+	if c.stats == nil {
+		c.stats = make(map[string]string)
+	}
+	c.stats[name] = value
+}
@@ -0,0 +1,91 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSentenceLengthCollector(t *testing.T) {
+	c := NewChain(1)
+	sl := collectorFactories["sentence-lengths"]()
+	term := collectorFactories["terminators"]()
+	c.AddCollector(sl)
+	c.AddCollector(term)
+	c.Build(strings.NewReader("a b. c d e! f?"))
+	if got := sl.Section(); got != `{"1":1,"2":1,"3":1}` {
+		t.Errorf("sentence-lengths section = %s", got)
+	}
+	if got := term.Section(); got != `{"b.":1,"e!":1,"f?":1}` {
+		t.Errorf("terminators section = %s", got)
+	}
+}
+
+func TestCountOfCountsCollector(t *testing.T) {
+	c := NewChain(1)
+	col := collectorFactories["count-of-counts"]()
+	c.AddCollector(col)
+	c.Build(strings.NewReader("a a a b b c"))
+	// One word occurs three times, one twice, one once.
+	if got := col.Section(); got != `{"1":1,"2":1,"3":1}` {
+		t.Errorf("count-of-counts section = %s", got)
+	}
+}
+
+func TestCollectorsCombinedMatchIndividual(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	text := strings.Repeat("the cat sat. the dog ran! was it the cat? ", 10)
+	if err := os.WriteFile(corpus, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	all := []string{"sentence-lengths", "terminators", "count-of-counts"}
+
+	build := func(collect string) map[string]string {
+		t.Helper()
+		model := filepath.Join(dir, "m.model")
+		if code, _, stderr := runCLI("read", "-prefix", "1", "-collect", collect, "-out", model, corpus); code != 0 {
+			t.Fatalf("read -collect %s exited %d: %s", collect, code, stderr)
+		}
+		c, err := loadModelFile(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.stats
+	}
+
+	combined := build(strings.Join(all, ","))
+	if len(combined) != len(all) {
+		t.Fatalf("combined run recorded %d sections, want %d: %v", len(combined), len(all), combined)
+	}
+	// One pass with all three enabled records exactly what three
+	// individual passes record.
+	for _, name := range all {
+		individual := build(name)
+		if individual[name] != combined[name] {
+			t.Errorf("section %s: combined %s, individual %s", name, combined[name], individual[name])
+		}
+	}
+}
+
+func TestParseCollectorsRejectsUnknown(t *testing.T) {
+	if _, err := parseCollectors("sentence-lengths,nope"); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("unknown collector error = %v", err)
+	}
+	if cols, err := parseCollectors(""); err != nil || cols != nil {
+		t.Errorf("empty -collect parsed to %v, %v", cols, err)
+	}
+}
+
+func TestStatsSurviveFormatRoundTrips(t *testing.T) {
+	c := maximalChain(t)
+	for name, trip := range map[string]*Chain{
+		"text": reload(t, c, c.writeModel, readModelStream),
+		"json": reload(t, c, c.writeJSONModel, readJSONModel),
+	} {
+		if trip.stats["terminators"] != c.stats["terminators"] {
+			t.Errorf("%s round trip lost the stats section: %v", name, trip.stats)
+		}
+	}
+}
@@ -1,3 +1,8 @@
+//go:build ignore
+
+// Scratch program from gobyexample used to learn the flag package.
+// Excluded from the build so it does not clash with the real main.
+
 package main
 
 import (
@@ -0,0 +1,85 @@
+package markov
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Flags that conflict are declared here, in one table, instead of in
+// scattered if-chains inside each mode: adding an option means adding
+// a row, and the conflict test iterates the table so every declared
+// row is known to trigger. Hard conflicts become usage errors naming
+// both options; where one option merely subsumes the other, the
+// combination is allowed with a warning.
+type optionConflict struct {
+	a, b    string // flag names without the leading dash
+	subsume bool   // a subsumes b: warn instead of failing
+	note    string // short explanation appended to the message
+}
+
+// conflictMatrix maps a mode to its declared conflicts.
+var conflictMatrix = map[string][]optionConflict{
+	"read": {
+		{a: "fail-fast", b: "strict", subsume: true,
+			note: "-fail-fast already fails the build at the first error"},
+		{a: "jobs", b: "continuous",
+			note: "the rolling prefix must cross files in input order, which a worker pool cannot honor"},
+		{a: "jobs", b: "collect",
+			note: "collectors observe the build as one ordered stream"},
+		{a: "update", b: "prefix",
+			note: "the existing model fixes the prefix length"},
+		{a: "update", b: "mode",
+			note: "the existing model fixes the chain level"},
+		{a: "update", b: "join-with",
+			note: "the existing model fixes the joiner"},
+		{a: "update", b: "split-on",
+			note: "the existing model fixes the tokenizer"},
+		{a: "update", b: "lowercase",
+			note: "the existing model fixes its normalization"},
+		{a: "update", b: "split-punct",
+			note: "the existing model fixes its normalization"},
+		{a: "update", b: "sentences",
+			note: "starts recorded for only the new text would skew the blend"},
+		{a: "update", b: "reverse",
+			note: "the existing model fixes whether the reverse index is tracked"},
+	},
+	"generate": {
+		{a: "sentences", b: "start",
+			note: "sentence mode picks its own starting prefix from the recorded sentence openings"},
+		{a: "temperature-schedule", b: "temperature", subsume: true,
+			note: "the schedule already supplies a per-step temperature"},
+		{a: "end", b: "start",
+			note: "a backward walk from -end cannot also continue forward from -start"},
+		{a: "end", b: "sentences",
+			note: "the backward walk is anchored by its ending phrase, not a sentence opening"},
+	},
+}
+
+// checkFlagConflicts applies the mode's declared conflicts to the
+// parsed flag set. It must run after fs.Parse.
+func checkFlagConflicts(mode string, fs *flag.FlagSet, stderr io.Writer) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return conflictErrors(conflictMatrix[mode], func(name string) bool { return set[name] }, stderr)
+}
+
+// conflictErrors evaluates one conflict table against the set of
+// explicitly provided flags.
+func conflictErrors(conflicts []optionConflict, isSet func(string) bool, stderr io.Writer) error {
+	for _, c := range conflicts {
+		if !isSet(c.a) || !isSet(c.b) {
+			continue
+		}
+		msg := fmt.Sprintf("-%s and -%s", c.a, c.b)
+		if c.note != "" {
+			msg += ": " + c.note
+		}
+		if c.subsume {
+			fmt.Fprintf(stderr, "WARNING: %s; ignoring -%s\n", msg, c.b)
+			continue
+		}
+		return &usageError{msg: "conflicting options " + msg}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package markov
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestEveryDeclaredConflictTriggers iterates the real matrix: for each
+// row, setting both flags must produce either a usage error or a
+// warning naming both, so stale rows (flags that were renamed away)
+// cannot sit in the table unnoticed.
+func TestEveryDeclaredConflictTriggers(t *testing.T) {
+	for mode, conflicts := range conflictMatrix {
+		for _, c := range conflicts {
+			t.Run(fmt.Sprintf("%s/-%s+-%s", mode, c.a, c.b), func(t *testing.T) {
+				bothSet := func(string) bool { return true }
+				var warnings strings.Builder
+				err := conflictErrors([]optionConflict{c}, bothSet, &warnings)
+				if c.subsume {
+					if err != nil {
+						t.Fatalf("subsuming pair returned an error: %v", err)
+					}
+					for _, name := range []string{"-" + c.a, "-" + c.b} {
+						if !strings.Contains(warnings.String(), name) {
+							t.Errorf("warning %q does not name %s", warnings.String(), name)
+						}
+					}
+					return
+				}
+				var ue *usageError
+				if !errors.As(err, &ue) {
+					t.Fatalf("hard conflict returned %v, want a usage error", err)
+				}
+				for _, name := range []string{"-" + c.a, "-" + c.b} {
+					if !strings.Contains(err.Error(), name) {
+						t.Errorf("error %q does not name %s", err, name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConflictsOnlyFireWhenBothFlagsAreSet(t *testing.T) {
+	table := []optionConflict{{a: "x", b: "y"}}
+	onlyX := func(name string) bool { return name == "x" }
+	if err := conflictErrors(table, onlyX, io.Discard); err != nil {
+		t.Errorf("conflict fired with only one flag set: %v", err)
+	}
+}
+
+func TestCheckFlagConflictsUsesExplicitlySetFlags(t *testing.T) {
+	// Defaults never conflict: only flags the user actually passed do.
+	fs := flag.NewFlagSet("mark read", flag.ContinueOnError)
+	fs.Bool("fail-fast", false, "")
+	fs.Bool("strict", false, "")
+	if err := fs.Parse([]string{"-fail-fast"}); err != nil {
+		t.Fatal(err)
+	}
+	var warnings strings.Builder
+	if err := checkFlagConflicts("read", fs, &warnings); err != nil || warnings.Len() > 0 {
+		t.Errorf("default-valued flag counted as set: err=%v warnings=%q", err, warnings.String())
+	}
+}
+
+func TestReadWarnsOnFailFastPlusStrict(t *testing.T) {
+	// Through the real CLI: the subsuming pair warns and proceeds.
+	code, _, stderr := runCLI("read", "-fail-fast", "-strict")
+	if code != exitUsage {
+		// Missing -out still fails; the point is the warning fired first.
+		t.Fatalf("read exited %d, want usage failure for the missing -out", code)
+	}
+	if !strings.Contains(stderr, "WARNING") || !strings.Contains(stderr, "-fail-fast") {
+		t.Errorf("no subsumption warning on stderr: %q", stderr)
+	}
+}
@@ -0,0 +1,311 @@
+package markov
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Corpus statistics are exact while the number of distinct tokens is
+// small. Past sketchDistinctThreshold the accumulator degrades to a
+// count-min sketch for top-token counts plus a linear counter for the
+// distinct estimate, so memory stays bounded on arbitrarily large
+// streams.
+const (
+	sketchDistinctThreshold = 1 << 16
+	sketchDepth             = 4
+	sketchWidth             = 1 << 15
+	linearCounterBits       = 1 << 20
+	hotTokenCap             = 256
+)
+
+// cmSketch is a count-min sketch: counts are over-estimates bounded by
+// the collision load, never under-estimates.
+type cmSketch struct {
+	rows [sketchDepth][sketchWidth]uint32
+}
+
+func sketchHashes(token string) (uint64, uint64) {
+	h := fnv.New64a()
+	io.WriteString(h, token)
+	h1 := h.Sum64()
+	// Second hash derived by extending the stream, for double hashing.
+	io.WriteString(h, "#")
+	return h1, h.Sum64() | 1
+}
+
+func (s *cmSketch) add(token string, n uint32) {
+	h1, h2 := sketchHashes(token)
+	for i := 0; i < sketchDepth; i++ {
+		s.rows[i][(h1+uint64(i)*h2)%sketchWidth] += n
+	}
+}
+
+func (s *cmSketch) estimate(token string) int {
+	h1, h2 := sketchHashes(token)
+	est := uint32(math.MaxUint32)
+	for i := 0; i < sketchDepth; i++ {
+		if v := s.rows[i][(h1+uint64(i)*h2)%sketchWidth]; v < est {
+			est = v
+		}
+	}
+	return int(est)
+}
+
+// tokenCount is one row of the top-token report.
+type tokenCount struct {
+	Token string `json:"token"`
+	Count int    `json:"count"`
+}
+
+// corpusStats is the finished report for one scope (a file, or all
+// inputs combined).
+type corpusStats struct {
+	Tokens         int          `json:"tokens"`
+	Distinct       int          `json:"distinct"`
+	Approximate    bool         `json:"approximate,omitempty"`
+	TypeTokenRatio float64      `json:"typeTokenRatio"`
+	Top            []tokenCount `json:"top,omitempty"`
+	Sentences      int          `json:"sentences,omitempty"`
+	AvgSentenceLen float64      `json:"avgSentenceLen,omitempty"`
+}
+
+// statsAccum accumulates token statistics for one scope.
+type statsAccum struct {
+	tokens    int
+	counts    map[string]int // exact counts; nil once degraded to the sketch
+	sketch    *cmSketch
+	hot       map[string]int // heavy-hitter candidates with estimated counts
+	bits      []uint64       // linear counter bitmap for distinct estimation
+	sentences int
+}
+
+func newStatsAccum() *statsAccum {
+	return &statsAccum{counts: make(map[string]int)}
+}
+
+// endsSentence reports whether the token terminates a sentence, after
+// stripping closing quotes and brackets.
+func endsSentence(token string) bool {
+	trimmed := strings.TrimRight(token, "\"')]”’")
+	return strings.HasSuffix(trimmed, ".") || strings.HasSuffix(trimmed, "!") || strings.HasSuffix(trimmed, "?")
+}
+
+func (a *statsAccum) observe(token string) {
+	a.tokens++
+	if endsSentence(token) {
+		a.sentences++
+	}
+	if a.counts != nil {
+		a.counts[token]++
+		if len(a.counts) > sketchDistinctThreshold {
+			a.degrade()
+		}
+		return
+	}
+	a.sketch.add(token, 1)
+	a.setBit(token)
+	a.noteHot(token, a.sketch.estimate(token))
+}
+
+// degrade moves the exact counts into the sketch path.
+func (a *statsAccum) degrade() {
+	a.sketch = new(cmSketch)
+	a.bits = make([]uint64, linearCounterBits/64)
+	a.hot = make(map[string]int, 2*hotTokenCap)
+	for tok, n := range a.counts {
+		a.sketch.add(tok, uint32(n))
+		a.setBit(tok)
+		a.noteHot(tok, n)
+	}
+	a.counts = nil
+}
+
+func (a *statsAccum) setBit(token string) {
+	h1, _ := sketchHashes(token)
+	bit := h1 % linearCounterBits
+	a.bits[bit/64] |= 1 << (bit % 64)
+}
+
+// noteHot keeps the candidate heavy-hitter set bounded: when full, the
+// smallest estimate is evicted.
+func (a *statsAccum) noteHot(token string, est int) {
+	if cur, ok := a.hot[token]; ok {
+		if est > cur {
+			a.hot[token] = est
+		}
+		return
+	}
+	if len(a.hot) < 2*hotTokenCap {
+		a.hot[token] = est
+		return
+	}
+	minTok, minEst := "", math.MaxInt
+	for t, e := range a.hot {
+		if e < minEst {
+			minTok, minEst = t, e
+		}
+	}
+	if est > minEst {
+		delete(a.hot, minTok)
+		a.hot[token] = est
+	}
+}
+
+// distinctEstimate inverts the linear counter: with m bits and z still
+// zero, the expected distinct count is m*ln(m/z).
+func (a *statsAccum) distinctEstimate() int {
+	zero := 0
+	for _, w := range a.bits {
+		zero += 64 - popcount(w)
+	}
+	if zero == 0 {
+		return linearCounterBits // saturated; lower bound
+	}
+	return int(linearCounterBits * math.Log(float64(linearCounterBits)/float64(zero)))
+}
+
+func popcount(w uint64) int {
+	n := 0
+	for ; w != 0; w &= w - 1 {
+		n++
+	}
+	return n
+}
+
+// finalize produces the report, with the top topN tokens by count.
+func (a *statsAccum) finalize(topN int) corpusStats {
+	st := corpusStats{Tokens: a.tokens, Sentences: a.sentences}
+	var top []tokenCount
+	if a.counts != nil {
+		st.Distinct = len(a.counts)
+		for tok, n := range a.counts {
+			top = append(top, tokenCount{tok, n})
+		}
+	} else {
+		st.Distinct = a.distinctEstimate()
+		st.Approximate = true
+		for tok, n := range a.hot {
+			top = append(top, tokenCount{tok, n})
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Token < top[j].Token
+	})
+	if len(top) > topN {
+		top = top[:topN]
+	}
+	st.Top = top
+	if a.tokens > 0 {
+		st.TypeTokenRatio = float64(st.Distinct) / float64(a.tokens)
+	}
+	if a.sentences > 0 {
+		st.AvgSentenceLen = float64(a.tokens) / float64(a.sentences)
+	}
+	return st
+}
+
+// runCorpusStats implements `mark corpus-stats`: raw corpus statistics
+// straight off the tokenizer, without building a chain.
+func runCorpusStats(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark corpus-stats", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	topN := fs.Int("top", 20, "how many top tokens to report")
+	asJSON := fs.Bool("json", false, "emit the report as JSON")
+	splitOn := fs.String("split-on", "", "regexp the tokenizer splits input on instead of whitespace")
+	sentences := fs.Bool("sentences", false, "detect sentence ends and report average sentence length")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		return &usageError{msg: "corpus-stats requires at least one input file"}
+	}
+	var splitRe *regexp.Regexp
+	if *splitOn != "" {
+		probe := NewChain(1)
+		if err := probe.SetTokenizer(*splitOn); err != nil {
+			return &usageError{msg: err.Error()}
+		}
+		splitRe = probe.splitRe
+	}
+
+	total := newStatsAccum()
+	type fileReport struct {
+		Name  string      `json:"name"`
+		Stats corpusStats `json:"stats"`
+	}
+	var files []fileReport
+	for _, name := range inputs {
+		fi, err := os.Open(name)
+		if err != nil {
+			return &notFoundError{what: "input", path: name, err: err}
+		}
+		acc := newStatsAccum()
+		err = eachToken(fi, splitRe, func(tok string) {
+			acc.observe(tok)
+			total.observe(tok)
+		})
+		fi.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		files = append(files, fileReport{name, acc.finalize(*topN)})
+	}
+	report := struct {
+		Files []fileReport `json:"files"`
+		Total corpusStats  `json:"total"`
+	}{files, total.finalize(*topN)}
+
+	if !*sentences {
+		report.Total.Sentences, report.Total.AvgSentenceLen = 0, 0
+		for i := range report.Files {
+			report.Files[i].Stats.Sentences, report.Files[i].Stats.AvgSentenceLen = 0, 0
+		}
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return &writeError{path: "stdout", err: err}
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+
+	printRow := func(name string, st corpusStats) {
+		distinct := fmt.Sprintf("%d", st.Distinct)
+		if st.Approximate {
+			distinct = "~" + distinct
+		}
+		fmt.Fprintf(stdout, "%-30s %10d %10s %8.3f", name, st.Tokens, distinct, st.TypeTokenRatio)
+		if *sentences {
+			fmt.Fprintf(stdout, " %8.1f", st.AvgSentenceLen)
+		}
+		fmt.Fprintln(stdout)
+	}
+	fmt.Fprintf(stdout, "%-30s %10s %10s %8s", "file", "tokens", "distinct", "ttr")
+	if *sentences {
+		fmt.Fprintf(stdout, " %8s", "avg-sent")
+	}
+	fmt.Fprintln(stdout)
+	for _, fr := range files {
+		printRow(fr.Name, fr.Stats)
+	}
+	printRow("TOTAL", report.Total)
+	fmt.Fprintln(stdout, "\ntop tokens:")
+	for _, tc := range report.Total.Top {
+		fmt.Fprintf(stdout, "  %-20s %10d\n", tc.Token, tc.Count)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package markov
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCorpusStatsExactValues(t *testing.T) {
+	acc := newStatsAccum()
+	for _, tok := range []string{"the", "cat", "the", "dog", "the", "cat."} {
+		acc.observe(tok)
+	}
+	st := acc.finalize(2)
+	if st.Tokens != 6 {
+		t.Errorf("Tokens = %d, want 6", st.Tokens)
+	}
+	if st.Distinct != 4 {
+		t.Errorf("Distinct = %d, want 4 (the, cat, dog, cat.)", st.Distinct)
+	}
+	if st.Approximate {
+		t.Error("small corpus took the sketch path")
+	}
+	if want := 4.0 / 6.0; st.TypeTokenRatio != want {
+		t.Errorf("TypeTokenRatio = %v, want %v", st.TypeTokenRatio, want)
+	}
+	if len(st.Top) != 2 || st.Top[0] != (tokenCount{"the", 3}) {
+		t.Errorf("Top = %v, want [{the 3} ...]", st.Top)
+	}
+	if st.Sentences != 1 {
+		t.Errorf("Sentences = %d, want 1 (cat.)", st.Sentences)
+	}
+	if want := 6.0; st.AvgSentenceLen != want {
+		t.Errorf("AvgSentenceLen = %v, want %v", st.AvgSentenceLen, want)
+	}
+}
+
+func TestCorpusStatsSketchPath(t *testing.T) {
+	acc := newStatsAccum()
+	// A synthetic stream with more distinct tokens than the threshold,
+	// plus one planted heavy hitter.
+	distinct := sketchDistinctThreshold + sketchDistinctThreshold/4
+	for i := 0; i < distinct; i++ {
+		acc.observe(fmt.Sprintf("tok%d", i))
+		if i%3 == 0 {
+			acc.observe("HEAVY")
+		}
+	}
+	st := acc.finalize(5)
+	if !st.Approximate {
+		t.Fatal("sketch path did not kick in above the threshold")
+	}
+	want := distinct + 1
+	if ratio := float64(st.Distinct) / float64(want); ratio < 0.95 || ratio > 1.05 {
+		t.Errorf("distinct estimate %d is off from %d by more than 5%%", st.Distinct, want)
+	}
+	if len(st.Top) == 0 || st.Top[0].Token != "HEAVY" {
+		t.Errorf("Top = %v, want HEAVY first", st.Top)
+	}
+	// Count-min estimates never undercount.
+	if st.Top[0].Count < distinct/3 {
+		t.Errorf("HEAVY count %d undercounts the true %d", st.Top[0].Count, distinct/3)
+	}
+}
+
+func TestCorpusStatsCLI(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("one two one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("two three\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr := runCLI("corpus-stats", "-json", a, b)
+	if code != 0 {
+		t.Fatalf("corpus-stats exited %d: %s", code, stderr)
+	}
+	var report struct {
+		Files []struct {
+			Name  string      `json:"name"`
+			Stats corpusStats `json:"stats"`
+		} `json:"files"`
+		Total corpusStats `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("got %d per-file reports, want 2", len(report.Files))
+	}
+	if report.Files[0].Stats.Tokens != 3 || report.Files[1].Stats.Tokens != 2 {
+		t.Errorf("per-file token counts = %d, %d, want 3, 2",
+			report.Files[0].Stats.Tokens, report.Files[1].Stats.Tokens)
+	}
+	if report.Total.Tokens != 5 || report.Total.Distinct != 3 {
+		t.Errorf("total = %d tokens/%d distinct, want 5/3", report.Total.Tokens, report.Total.Distinct)
+	}
+
+	// Text output, with the table header and TOTAL row.
+	code, stdout, _ = runCLI("corpus-stats", a, b)
+	if code != 0 {
+		t.Fatalf("corpus-stats text exited %d", code)
+	}
+	for _, want := range []string{"file", "TOTAL", "top tokens:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("text report is missing %q:\n%s", want, stdout)
+		}
+	}
+}
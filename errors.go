@@ -0,0 +1,178 @@
+package markov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Exit codes reported by the CLI. Each error category maps to exactly one
+// code so scripts wrapping the binary can branch on failures.
+const (
+	exitUsage        = 1 // bad command line: unknown mode, bad flag values
+	exitNotFound     = 2 // an input, model, or output file could not be opened
+	exitCorrupt      = 3 // a model file exists but could not be parsed or is empty
+	exitWrite        = 4 // output could not be written
+	exitConstraints  = 5 // generation could not satisfy the requested constraints
+	exitIncompatible = 6 // models with different shapes were combined
+)
+
+// Sentinel errors the public APIs wrap their failures with, so callers
+// can branch on failure kinds with errors.Is regardless of how many
+// layers of context have been added. Errors that carry extra detail
+// (prefix lengths, line numbers) additionally match a concrete type
+// via errors.As.
+var (
+	ErrEmptyChain             = errors.New("chain has no entries")
+	ErrUnknownPrefix          = errors.New("prefix not in chain")
+	ErrIncompatibleModels     = errors.New("incompatible models")
+	ErrModelCorrupt           = errors.New("model corrupt")
+	ErrConstraintsUnsatisfied = errors.New("constraints unsatisfied")
+)
+
+// incompatibleModelsError reports an attempt to combine chains built
+// with different prefix lengths; it carries both so the message (and
+// errors.As callers) can name them.
+type incompatibleModelsError struct {
+	a, b int
+}
+
+func (e *incompatibleModelsError) Error() string {
+	return fmt.Sprintf("incompatible models: prefix length %d vs %d", e.a, e.b)
+}
+
+func (e *incompatibleModelsError) Is(target error) bool { return target == ErrIncompatibleModels }
+
+// usageError reports a problem with the command line itself.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+// notFoundError reports a file (corpus, model, or output) that could not
+// be opened or created.
+type notFoundError struct {
+	what string // "input", "model", "output"
+	path string
+	err  error
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %s", e.what, e.path)
+}
+
+func (e *notFoundError) Unwrap() error { return e.err }
+
+// corruptError reports a model file that was opened but could not be
+// parsed. line is 1-based; 0 means the position is unknown.
+type corruptError struct {
+	path string
+	line int
+	msg  string
+}
+
+func (e *corruptError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("model corrupt: %s:%d: %s", e.path, e.line, e.msg)
+	}
+	return fmt.Sprintf("model corrupt: %s: %s", e.path, e.msg)
+}
+
+func (e *corruptError) Is(target error) bool { return target == ErrModelCorrupt }
+
+// writeError reports a failure writing program output.
+type writeError struct {
+	path string
+	err  error
+}
+
+func (e *writeError) Error() string {
+	return fmt.Sprintf("cannot write %s: %v", e.path, e.err)
+}
+
+func (e *writeError) Unwrap() error { return e.err }
+
+// exitCode maps an error from run to the process exit code. It is
+// written purely in terms of errors.Is/As so it keeps working however
+// deeply a failure has been wrapped, and so it stays the single source
+// of truth alongside httpStatus.
+func exitCode(err error) int {
+	var ue *usageError
+	var nf *notFoundError
+	var we *writeError
+	var be *BuildErrors
+	switch {
+	case errors.As(err, &ue):
+		return exitUsage
+	case errors.As(err, &be):
+		return be.exitCode()
+	case errors.As(err, &nf):
+		return exitNotFound
+	case errors.Is(err, ErrModelCorrupt), errors.Is(err, ErrEmptyChain):
+		return exitCorrupt
+	case errors.As(err, &we):
+		return exitWrite
+	case errors.Is(err, ErrUnknownPrefix), errors.Is(err, ErrConstraintsUnsatisfied):
+		return exitConstraints
+	case errors.Is(err, ErrIncompatibleModels):
+		return exitIncompatible
+	}
+	return exitUsage
+}
+
+// httpStatus maps the same error categories onto HTTP status codes for
+// serve mode, using only errors.Is/As like exitCode so the two stay in
+// sync by construction.
+func httpStatus(err error) int {
+	var nf *notFoundError
+	switch {
+	case errors.As(err, &nf):
+		return 404
+	case errors.Is(err, ErrUnknownPrefix), errors.Is(err, ErrConstraintsUnsatisfied),
+		errors.Is(err, ErrIncompatibleModels):
+		return 400
+	}
+	return 500
+}
+
+// errorJSON is the machine-readable error shape emitted with
+// -error-format json: a single JSON object on one stderr line.
+type errorJSON struct {
+	Error string `json:"error"`
+	Path  string `json:"path,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Code  int    `json:"code"`
+}
+
+// reportError renders err on stderr, either as human-readable text
+// (the default) or as one JSON object when format is "json".
+func reportError(stderr io.Writer, format string, err error) {
+	if format == "json" {
+		obj := errorJSON{Error: err.Error(), Code: exitCode(err)}
+		var nf *notFoundError
+		var ce *corruptError
+		var we *writeError
+		var bes *BuildErrors
+		switch {
+		case errors.As(err, &bes):
+			// A single failed input is pointed at directly; several
+			// are only summarized, the list does not fit one field.
+			if len(bes.Errs) == 1 {
+				obj.Path = bes.Errs[0].Input
+			}
+		case errors.As(err, &nf):
+			obj.Path = nf.path
+		case errors.As(err, &ce):
+			obj.Path = ce.path
+			obj.Line = ce.line
+		case errors.As(err, &we):
+			obj.Path = we.path
+		}
+		b, _ := json.Marshal(obj)
+		fmt.Fprintln(stderr, string(b))
+		return
+	}
+	fmt.Fprintln(stderr, "mark:", err)
+}
@@ -0,0 +1,94 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// wrapTwice adds two layers of context, the way errors propagate from
+// the chain through the CLI, so Is/As matching is tested through depth.
+func wrapTwice(err error) error {
+	return fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", err))
+}
+
+func TestSentinelsMatchThroughWrapping(t *testing.T) {
+	for _, sentinel := range []error{
+		ErrEmptyChain,
+		ErrUnknownPrefix,
+		ErrIncompatibleModels,
+		ErrModelCorrupt,
+		ErrConstraintsUnsatisfied,
+	} {
+		if !errors.Is(wrapTwice(sentinel), sentinel) {
+			t.Errorf("errors.Is failed through two wraps for %v", sentinel)
+		}
+	}
+}
+
+func TestIncompatibleModelsCarriesBothLengths(t *testing.T) {
+	err := wrapTwice(&incompatibleModelsError{a: 2, b: 3})
+	if !errors.Is(err, ErrIncompatibleModels) {
+		t.Fatal("errors.Is(err, ErrIncompatibleModels) = false")
+	}
+	var ime *incompatibleModelsError
+	if !errors.As(err, &ime) {
+		t.Fatal("errors.As failed to recover the concrete error")
+	}
+	if ime.a != 2 || ime.b != 3 {
+		t.Errorf("prefix lengths = %d, %d, want 2, 3", ime.a, ime.b)
+	}
+}
+
+func TestModelCorruptCarriesLine(t *testing.T) {
+	err := wrapTwice(&corruptError{path: "m.txt", line: 7, msg: "bad count"})
+	if !errors.Is(err, ErrModelCorrupt) {
+		t.Fatal("errors.Is(err, ErrModelCorrupt) = false")
+	}
+	var ce *corruptError
+	if !errors.As(err, &ce) {
+		t.Fatal("errors.As failed to recover the concrete error")
+	}
+	if ce.line != 7 {
+		t.Errorf("line = %d, want 7", ce.line)
+	}
+}
+
+func TestExitCodeMapping(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{&usageError{msg: "x"}, exitUsage},
+		{wrapTwice(&notFoundError{what: "model", path: "m.txt"}), exitNotFound},
+		{wrapTwice(&corruptError{line: 2, msg: "x"}), exitCorrupt},
+		{wrapTwice(ErrEmptyChain), exitCorrupt},
+		{wrapTwice(&writeError{path: "out"}), exitWrite},
+		{wrapTwice(ErrUnknownPrefix), exitConstraints},
+		{wrapTwice(ErrConstraintsUnsatisfied), exitConstraints},
+		{wrapTwice(&incompatibleModelsError{a: 1, b: 2}), exitIncompatible},
+	}
+	for _, tc := range cases {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestHTTPStatusMapping(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{wrapTwice(&notFoundError{what: "model", path: "m.txt"}), 404},
+		{wrapTwice(ErrUnknownPrefix), 400},
+		{wrapTwice(ErrConstraintsUnsatisfied), 400},
+		{wrapTwice(&incompatibleModelsError{a: 1, b: 2}), 400},
+		{wrapTwice(ErrModelCorrupt), 500},
+	}
+	for _, tc := range cases {
+		if got := httpStatus(tc.err); got != tc.want {
+			t.Errorf("httpStatus(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The flat model format exists for near-zero-startup serving: unlike
+// the text format (and unlike gob, which must be decoded), it is a
+// fixed-layout file that can be memory-mapped and searched in place,
+// so the OS page cache holds the model and multiple server processes
+// share it.
+//
+// Layout, all integers little-endian uint32 unless noted:
+//
+//	magic "MARKFLAT" (8 bytes), version (1 byte)
+//	prefixLen, joiner length + joiner bytes
+//	key count, key table length, entry section length, blob length
+//	key table:  per key, sorted by key bytes:
+//	            blob offset, key length, entry offset, entry count
+//	entries:    per suffix, sorted by suffix bytes within a key:
+//	            blob offset, suffix length, count
+//	blob:       interned string bytes (each distinct string stored once)
+type flatModel struct {
+	data      []byte
+	closeFn   func() error
+	prefixLen int
+	joinWith  string
+	numKeys   int
+	keyTable  []byte
+	entries   []byte
+	blob      []byte
+}
+
+var flatMagic = [8]byte{'M', 'A', 'R', 'K', 'F', 'L', 'A', 'T'}
+
+const (
+	flatVersion      = 1
+	flatKeyEntrySize = 16 // offset, length, entry offset, entry count
+	flatSuffixSize   = 16 // offset, length, weight (float64 bits)
+)
+
+// writeFlatModel writes the chain as a flat model. Keys and suffixes
+// are emitted in sorted order, so lookups can binary-search and
+// sampling visits suffixes in the same order weightedChoice does.
+func (c *Chain) writeFlatModel(w io.Writer) error {
+	keys := make([]string, 0, len(c.chain))
+	for key := range c.chain {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var blob, keyTable, entries bytes.Buffer
+	interned := make(map[string]uint32)
+	intern := func(s string) uint32 {
+		if off, ok := interned[s]; ok {
+			return off
+		}
+		off := uint32(blob.Len())
+		interned[s] = off
+		blob.WriteString(s)
+		return off
+	}
+	u32 := func(b *bytes.Buffer, v int) {
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+		b.Write(tmp[:])
+	}
+
+	for _, key := range keys {
+		counts := c.chain[key]
+		suffixes := make([]string, 0, len(counts))
+		for s := range counts {
+			suffixes = append(suffixes, s)
+		}
+		sort.Strings(suffixes)
+		entryOff := entries.Len()
+		for _, s := range suffixes {
+			u32(&entries, int(intern(s)))
+			u32(&entries, len(s))
+			var wbits [8]byte
+			binary.LittleEndian.PutUint64(wbits[:], math.Float64bits(counts[s]))
+			entries.Write(wbits[:])
+		}
+		u32(&keyTable, int(intern(key)))
+		u32(&keyTable, len(key))
+		u32(&keyTable, entryOff)
+		u32(&keyTable, len(suffixes))
+	}
+
+	var header bytes.Buffer
+	header.Write(flatMagic[:])
+	header.WriteByte(flatVersion)
+	u32(&header, c.prefixLen)
+	u32(&header, len(c.joinWith))
+	header.WriteString(c.joinWith)
+	u32(&header, len(keys))
+	u32(&header, keyTable.Len())
+	u32(&header, entries.Len())
+	u32(&header, blob.Len())
+
+	for _, section := range []*bytes.Buffer{&header, &keyTable, &entries, &blob} {
+		if _, err := w.Write(section.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openFlatModel maps the file read-only (falling back to a full read
+// on platforms without mmap support) and validates the section layout.
+func openFlatModel(path string) (*flatModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &notFoundError{what: "model", path: path, err: err}
+	}
+	defer f.Close()
+	data, closeFn, err := mmapFile(f)
+	if err != nil {
+		return nil, &corruptError{path: path, msg: err.Error()}
+	}
+	m, err := parseFlatModel(data)
+	if err != nil {
+		closeFn()
+		return nil, &corruptError{path: path, msg: err.Error()}
+	}
+	m.closeFn = closeFn
+	return m, nil
+}
+
+// isFlatModel reports whether the file starts with the flat magic.
+func isFlatModel(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var head [8]byte
+	if _, err := io.ReadFull(f, head[:]); err != nil {
+		return false
+	}
+	return head == flatMagic
+}
+
+// parseFlatModel validates the header and slices the sections out of
+// the mapped bytes without copying them.
+func parseFlatModel(data []byte) (*flatModel, error) {
+	if len(data) < 9 || !bytes.Equal(data[:8], flatMagic[:]) {
+		return nil, fmt.Errorf("not a flat model file")
+	}
+	if data[8] != flatVersion {
+		return nil, fmt.Errorf("unsupported flat model version %d", data[8])
+	}
+	rest := data[9:]
+	next := func(n int) ([]byte, error) {
+		if len(rest) < n {
+			return nil, fmt.Errorf("flat model truncated")
+		}
+		b := rest[:n]
+		rest = rest[n:]
+		return b, nil
+	}
+	u32 := func() (int, error) {
+		b, err := next(4)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint32(b)), nil
+	}
+
+	m := &flatModel{data: data}
+	var joinLen, keyTableLen, entriesLen, blobLen int
+	var errAll error
+	read := func(dst *int) {
+		if errAll == nil {
+			*dst, errAll = u32()
+		}
+	}
+	read(&m.prefixLen)
+	read(&joinLen)
+	if errAll == nil {
+		var join []byte
+		join, errAll = next(joinLen)
+		m.joinWith = string(join)
+	}
+	read(&m.numKeys)
+	read(&keyTableLen)
+	read(&entriesLen)
+	read(&blobLen)
+	if errAll == nil && m.numKeys*flatKeyEntrySize != keyTableLen {
+		errAll = fmt.Errorf("key table length %d does not match %d keys", keyTableLen, m.numKeys)
+	}
+	if errAll == nil {
+		m.keyTable, errAll = next(keyTableLen)
+	}
+	if errAll == nil {
+		m.entries, errAll = next(entriesLen)
+	}
+	if errAll == nil {
+		m.blob, errAll = next(blobLen)
+	}
+	if errAll != nil {
+		return nil, errAll
+	}
+	if m.prefixLen < 1 {
+		return nil, fmt.Errorf("flat model has prefix length %d", m.prefixLen)
+	}
+	// Validate every row's offsets up front so lookups over a doctored
+	// file can never index outside the mapped sections.
+	for i := 0; i < m.numKeys; i++ {
+		row := m.keyTable[i*flatKeyEntrySize:]
+		keyOff := int(binary.LittleEndian.Uint32(row))
+		keyLen := int(binary.LittleEndian.Uint32(row[4:]))
+		entryOff := int(binary.LittleEndian.Uint32(row[8:]))
+		entryCount := int(binary.LittleEndian.Uint32(row[12:]))
+		if keyOff+keyLen > len(m.blob) || entryOff+entryCount*flatSuffixSize > len(m.entries) {
+			return nil, fmt.Errorf("flat model key row %d points outside its sections", i)
+		}
+	}
+	for off := 0; off+flatSuffixSize <= len(m.entries); off += flatSuffixSize {
+		strOff := int(binary.LittleEndian.Uint32(m.entries[off:]))
+		strLen := int(binary.LittleEndian.Uint32(m.entries[off+4:]))
+		if strOff+strLen > len(m.blob) {
+			return nil, fmt.Errorf("flat model entry at %d points outside the blob", off)
+		}
+	}
+	return m, nil
+}
+
+func (m *flatModel) Close() error {
+	if m.closeFn != nil {
+		return m.closeFn()
+	}
+	return nil
+}
+
+// keyAt returns the i-th key's bytes straight out of the mapped blob.
+func (m *flatModel) keyAt(i int) []byte {
+	row := m.keyTable[i*flatKeyEntrySize:]
+	off := binary.LittleEndian.Uint32(row)
+	n := binary.LittleEndian.Uint32(row[4:])
+	return m.blob[off : off+n]
+}
+
+// lookup binary-searches the sorted key table for the prefix and
+// returns its entry rows, or nil when the prefix is unknown.
+func (m *flatModel) lookup(key string) []byte {
+	i := sort.Search(m.numKeys, func(i int) bool {
+		return bytes.Compare(m.keyAt(i), []byte(key)) >= 0
+	})
+	if i == m.numKeys || !bytes.Equal(m.keyAt(i), []byte(key)) {
+		return nil
+	}
+	row := m.keyTable[i*flatKeyEntrySize:]
+	entryOff := binary.LittleEndian.Uint32(row[8:])
+	entryCount := binary.LittleEndian.Uint32(row[12:])
+	return m.entries[entryOff : entryOff+entryCount*flatSuffixSize]
+}
+
+// pickSuffix samples one suffix from the entry rows with probability
+// proportional to its weight, visiting suffixes in the same sorted
+// order weightedChoice uses and drawing through pickWeighted so both
+// loaders make identical draws from the same seed.
+func (m *flatModel) pickSuffix(rows []byte) string {
+	n := len(rows) / flatSuffixSize
+	i := pickWeighted(nil, n, func(i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(rows[i*flatSuffixSize+8:]))
+	})
+	if i < 0 {
+		return ""
+	}
+	off := i * flatSuffixSize
+	strOff := binary.LittleEndian.Uint32(rows[off:])
+	strLen := binary.LittleEndian.Uint32(rows[off+4:])
+	return string(m.blob[strOff : strOff+strLen])
+}
+
+// Generate mirrors Chain.Generate over the mapped representation.
+func (m *flatModel) Generate(n int) string {
+	text, _ := m.generateCounted(n)
+	return text
+}
+
+// generateCounted is Generate returning the number of words produced
+// as well, for callers that need to tell a dead end from a cutoff.
+func (m *flatModel) generateCounted(n int) (string, int) {
+	p := make(Prefix, m.prefixLen)
+	for i := range p {
+		p[i] = "\"\""
+	}
+	var words []string
+	for i := 0; i < n; i++ {
+		rows := m.lookup(p.String())
+		if len(rows) == 0 {
+			break
+		}
+		next := m.pickSuffix(rows)
+		words = append(words, next)
+		p.Shift(next)
+	}
+	return strings.Join(words, m.joinWith), len(words)
+}
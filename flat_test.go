@@ -0,0 +1,169 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchChain builds a chain over a synthetic Zipf corpus with a few
+// thousand prefixes for the loader comparisons. The fixed seed keeps
+// the chain identical across machines and runs.
+func benchChain(tb testing.TB) *Chain {
+	tb.Helper()
+	var corpus bytes.Buffer
+	spec := corpusSpec{words: 6000, vocab: 97, zipfS: 1.3, seed: 7, sentenceLen: 15}
+	if err := synthCorpus(&corpus, spec); err != nil {
+		tb.Fatal(err)
+	}
+	c := NewChain(2)
+	c.Build(&corpus)
+	return c
+}
+
+func writeFlatFile(tb testing.TB, c *Chain) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "model.flat")
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := c.writeFlatModel(f); err != nil {
+		tb.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestFlatModelGenerationMatchesInMemory(t *testing.T) {
+	c := benchChain(t)
+	m, err := openFlatModel(writeFlatFile(t, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if m.prefixLen != c.prefixLen {
+		t.Fatalf("flat prefixLen = %d, want %d", m.prefixLen, c.prefixLen)
+	}
+	// Both loaders visit suffixes in sorted order, so with the same
+	// seed they must make identical draws.
+	for seed := int64(1); seed <= 5; seed++ {
+		rand.Seed(seed)
+		want := c.Generate(200)
+		rand.Seed(seed)
+		got := m.Generate(200)
+		if got != want {
+			t.Fatalf("seed %d: flat output diverges from in-memory output\nflat: %q\nmem:  %q", seed, got, want)
+		}
+	}
+}
+
+func TestFlatModelUnknownPrefix(t *testing.T) {
+	c := benchChain(t)
+	m, err := openFlatModel(writeFlatFile(t, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if rows := m.lookup("never seen"); rows != nil {
+		t.Errorf("lookup of unknown prefix returned %d bytes of rows", len(rows))
+	}
+}
+
+func TestFlatModelRejectsCorruption(t *testing.T) {
+	c := benchChain(t)
+	var buf bytes.Buffer
+	if err := c.writeFlatModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, err := parseFlatModel(data[:20]); err == nil {
+		t.Error("truncated flat model parsed without error")
+	}
+	if _, err := parseFlatModel([]byte("not a flat model at all")); err == nil {
+		t.Error("non-flat bytes parsed without error")
+	}
+	bad := append([]byte(nil), data...)
+	bad[8] = 99 // unsupported version
+	if _, err := parseFlatModel(bad); err == nil {
+		t.Error("unsupported version parsed without error")
+	}
+}
+
+func TestGenerateAutoDetectsFlatModel(t *testing.T) {
+	c := benchChain(t)
+	path := writeFlatFile(t, c)
+	code, stdout, stderr := runCLI("generate", "-model", path, "-words", "20")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if strings.TrimSpace(stdout) == "" {
+		t.Error("no output generated from the flat model")
+	}
+}
+
+func TestReadWritesFlatFormat(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("tick tock ", 50)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.flat")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-format", "flat", "-out", out, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if !isFlatModel(out) {
+		t.Error("written file does not carry the flat magic")
+	}
+}
+
+// The startup benchmarks demonstrate why the flat format exists: the
+// text loader parses and allocates the whole chain up front, while the
+// flat loader maps the file and validates offsets.
+func BenchmarkStartupTextModel(b *testing.B) {
+	c := benchChain(b)
+	path := filepath.Join(b.TempDir(), "model.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := c.writeModel(f); err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Scan() // header
+		loaded := NewChain(c.prefixLen)
+		if err := loaded.BuildFromRead(sc, c.prefixLen); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkStartupFlatModel(b *testing.B) {
+	c := benchChain(b)
+	path := writeFlatFile(b, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, err := openFlatModel(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.Close()
+	}
+}
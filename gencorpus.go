@@ -0,0 +1,85 @@
+package markov
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Benchmarks and stress tests need corpora far too large to check in.
+// synthCorpus generates one deterministically from a seed: a fixed
+// vocabulary whose token frequencies follow a Zipf distribution (like
+// natural text does), chopped into sentences of bounded random length.
+// The same spec produces byte-identical output on every machine.
+type corpusSpec struct {
+	words       int     // total tokens to emit
+	vocab       int     // distinct vocabulary size
+	zipfS       float64 // Zipf exponent, must be > 1
+	seed        int64
+	sentenceLen int // mean tokens per sentence
+}
+
+// synthCorpus writes the synthetic corpus described by the spec to w.
+func synthCorpus(w io.Writer, spec corpusSpec) error {
+	if spec.vocab < 1 || spec.words < 0 || spec.zipfS <= 1 || spec.sentenceLen < 1 {
+		return fmt.Errorf("invalid corpus spec %+v", spec)
+	}
+	rng := rand.New(rand.NewSource(spec.seed))
+	zipf := rand.NewZipf(rng, spec.zipfS, 1, uint64(spec.vocab-1))
+	bw := bufio.NewWriter(w)
+	remaining := spec.words
+	for remaining > 0 {
+		// Sentence lengths vary uniformly between half and one and a
+		// half times the mean, so prefixes cross sentence boundaries
+		// about as often as they would in prose.
+		n := spec.sentenceLen/2 + rng.Intn(spec.sentenceLen) + 1
+		if n > remaining {
+			n = remaining
+		}
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			fmt.Fprintf(bw, "w%d", zipf.Uint64())
+		}
+		bw.WriteString(".\n")
+		remaining -= n
+	}
+	return bw.Flush()
+}
+
+// runGenCorpus implements the gen-corpus mode. It is deliberately
+// undocumented in the usage line: it exists for benchmarks and stress
+// tests, not for end users.
+func runGenCorpus(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark gen-corpus", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	words := fs.Int("words", 1_000_000, "total tokens to generate")
+	vocab := fs.Int("vocab", 50_000, "distinct vocabulary size")
+	zipfS := fs.Float64("zipf", 1.1, "Zipf exponent of the token distribution (> 1)")
+	seed := fs.Int64("seed", 7, "random seed; the same seed always produces the same corpus")
+	out := fs.String("o", "", "output file (default stdout)")
+	sentenceLen := fs.Int("sentence-len", 15, "mean tokens per sentence")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *words < 0 {
+		return &usageError{msg: "-words must not be negative"}
+	}
+	if *vocab < 1 {
+		return &usageError{msg: "-vocab must be at least 1"}
+	}
+	if *zipfS <= 1 {
+		return &usageError{msg: "-zipf must be greater than 1"}
+	}
+	if *sentenceLen < 1 {
+		return &usageError{msg: "-sentence-len must be at least 1"}
+	}
+	spec := corpusSpec{words: *words, vocab: *vocab, zipfS: *zipfS, seed: *seed, sentenceLen: *sentenceLen}
+	if *out == "" {
+		return synthCorpus(stdout, spec)
+	}
+	return atomicWriteFile(*out, func(w io.Writer) error { return synthCorpus(w, spec) })
+}
@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSynthCorpusDeterministic(t *testing.T) {
+	spec := corpusSpec{words: 10_000, vocab: 500, zipfS: 1.2, seed: 7, sentenceLen: 12}
+	var a, b bytes.Buffer
+	if err := synthCorpus(&a, spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := synthCorpus(&b, spec); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("same spec produced different corpora")
+	}
+	spec.seed = 8
+	var c bytes.Buffer
+	if err := synthCorpus(&c, spec); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.Bytes(), c.Bytes()) {
+		t.Error("different seeds produced identical corpora")
+	}
+}
+
+// corpusTokenCounts tallies the vocabulary tokens in a corpus, with the
+// sentence-final period stripped.
+func corpusTokenCounts(s string) map[string]int {
+	counts := make(map[string]int)
+	for _, tok := range strings.Fields(s) {
+		counts[strings.TrimSuffix(tok, ".")]++
+	}
+	return counts
+}
+
+func TestSynthCorpusVocabulary(t *testing.T) {
+	var buf bytes.Buffer
+	spec := corpusSpec{words: 50_000, vocab: 50, zipfS: 1.1, seed: 3, sentenceLen: 10}
+	if err := synthCorpus(&buf, spec); err != nil {
+		t.Fatal(err)
+	}
+	counts := corpusTokenCounts(buf.String())
+	if len(counts) > spec.vocab {
+		t.Errorf("corpus uses %d distinct tokens, vocab is %d", len(counts), spec.vocab)
+	}
+	// With a gentle exponent and 50k draws over 50 words, essentially
+	// the whole vocabulary should appear.
+	if len(counts) < spec.vocab*9/10 {
+		t.Errorf("corpus uses only %d of %d vocabulary tokens", len(counts), spec.vocab)
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total != spec.words {
+		t.Errorf("corpus has %d tokens, want %d", total, spec.words)
+	}
+}
+
+func TestSynthCorpusZipfExponent(t *testing.T) {
+	// With value parameter 1 the draw frequencies follow (1+rank)^-s,
+	// so s is recoverable from the rank-0 to rank-3 count ratio:
+	// f0/f3 = 4^s.
+	var buf bytes.Buffer
+	spec := corpusSpec{words: 200_000, vocab: 1000, zipfS: 1.5, seed: 11, sentenceLen: 15}
+	if err := synthCorpus(&buf, spec); err != nil {
+		t.Fatal(err)
+	}
+	counts := corpusTokenCounts(buf.String())
+	f0, f3 := float64(counts["w0"]), float64(counts["w3"])
+	if f0 == 0 || f3 == 0 {
+		t.Fatalf("head-of-distribution tokens missing: w0=%v w3=%v", f0, f3)
+	}
+	got := math.Log(f0/f3) / math.Log(4)
+	if math.Abs(got-spec.zipfS) > 0.2 {
+		t.Errorf("estimated Zipf exponent %.3f, want about %.1f", got, spec.zipfS)
+	}
+}
+
+func TestGenCorpusCLI(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "corpus.txt")
+	code, _, stderr := runCLI("gen-corpus", "-words", "1000", "-vocab", "100", "-seed", "7", "-o", out)
+	if code != 0 {
+		t.Fatalf("gen-corpus exited %d: %s", code, stderr)
+	}
+	if code, _, _ := runCLI("gen-corpus", "-zipf", "1.0"); code != exitUsage {
+		t.Errorf("gen-corpus -zipf 1.0 exited %d, want %d", code, exitUsage)
+	}
+}
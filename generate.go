@@ -0,0 +1,317 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// deadlineCheckEvery is how many words are emitted between deadline
+// checks, so a MaxDuration cap does not pay for time.Now on every word.
+const deadlineCheckEvery = 16
+
+// stepBudgetFactor derives the default sampling-step budget from the
+// word target. One step currently yields one word, but features that
+// retry or restart (fills, backoff) can take several steps per emitted
+// word, and the budget bounds the total work whichever are active.
+const stepBudgetFactor = 4
+
+// GenerateOptions control a single generation run. The zero value with
+// only Words set reproduces Generate's behavior.
+type GenerateOptions struct {
+	// Words is the maximum number of words to generate.
+	Words int
+	// MaxDuration, when positive, is a hard latency cap: generation
+	// stops once the deadline passes and returns what was produced.
+	MaxDuration time.Duration
+	// OnWord, when non-nil, is called after each word is chosen.
+	OnWord func(word string)
+	// Temperature, when positive, reshapes every draw: weights become
+	// w^(1/T), so values below 1 sharpen and above 1 flatten. Zero
+	// means 1 (no reshaping).
+	Temperature float64
+	// TemperatureAt, when non-nil, supplies a per-step temperature and
+	// takes precedence over Temperature; see linearSchedule.
+	TemperatureAt func(step, totalWords int) float64
+	// TopK, when positive, restricts each draw to the K heaviest
+	// suffixes of the current prefix. Suffixes tied with the K-th
+	// heaviest all stay in — dropping alphabetically-unlucky equals
+	// would bias the draw — and a prefix with at most K suffixes is
+	// untouched. Zero means no cutoff.
+	TopK int
+	// MaxSteps, when positive, caps the number of sampling steps the
+	// run may take regardless of how many words they yield. Zero
+	// derives Words * stepBudgetFactor.
+	MaxSteps int
+	// CountPunct makes punctuation-only tokens consume the Words
+	// budget. By default they are emitted but do not count: "a hundred
+	// words" means a hundred words, not ninety words and ten dashes.
+	CountPunct bool
+	// Start, when non-empty, seeds the prefix window from the phrase's
+	// last prefixLen tokens — words, or runes on a character-level
+	// chain — left-padded with the sentinel when the phrase is
+	// shorter, the way Build pads. When the seeded key is
+	// unknown the walk restarts from a random known prefix instead of
+	// dead-ending immediately. Only the continuation is returned and
+	// counted; callers that want the phrase in the output prepend it,
+	// as GenerateFrom does.
+	Start string
+	// Backoff keeps the walk alive at dead ends: a window with no
+	// continuations retries on progressively shorter tails of itself
+	// (see backoffChoices) and finally restarts from a random known
+	// key. Off by default so existing runs are unchanged.
+	Backoff bool
+	// Sentences starts the walk from a recorded sentence-opening prefix
+	// (see TrackSentenceStarts), emitting its words first, and keeps
+	// going past the word budget until a word ending in terminal
+	// punctuation, hard-capped at twice the budget for corpora that
+	// never punctuate. A chain with no recorded starts begins at the
+	// sentinel as usual. Ignored when Start supplies a phrase.
+	Sentences bool
+	// Sink, when non-nil, receives each word (with the joiner between
+	// them) as it is produced instead of the run accumulating Text.
+	// Long outputs then stream in constant memory; Text comes back
+	// empty, Words counts the units written, and the first write
+	// failure stops the walk and lands in WriteErr.
+	Sink io.Writer
+}
+
+// TemperatureFor resolves the effective temperature for one step: the
+// schedule when set, the constant Temperature when positive, else 1.
+func (o GenerateOptions) TemperatureFor(step int) float64 {
+	if o.TemperatureAt != nil {
+		if t := o.TemperatureAt(step, o.Words); t > 0 {
+			return t
+		}
+	}
+	if o.Temperature > 0 {
+		return o.Temperature
+	}
+	return 1
+}
+
+// GenerateResult is the generated text plus metadata about the run.
+// The accounting contract: Words always equals the number of
+// whitespace-separated units in Text, sentinel tokens are never
+// emitted or counted, and punctuation-only tokens consume the word
+// budget only under CountPunct.
+type GenerateResult struct {
+	Text     string
+	Words    int
+	Emitted  int // tokens the walk produced; equals Words under the space joiner
+	Elapsed  time.Duration
+	TimedOut bool // true when the MaxDuration cutoff fired
+	// StoppedEarly names the cutoff that ended the run before the word
+	// target: "deadline" or "step budget". Empty when the run finished
+	// normally (word target reached or the walk dead-ended).
+	StoppedEarly string
+	// SuspectCorrupt is set when the walk's lookups missed without a
+	// single word being produced on a non-empty chain — the signature
+	// of a model whose keys disagree with its prefix length, as
+	// opposed to the normal dead-end that ends a walk early.
+	SuspectCorrupt bool
+	// WriteErr is the first error the Sink returned; the walk stops
+	// where it struck. Always nil without a Sink.
+	WriteErr error
+}
+
+// GenerateWithOptions generates text from the chain under the given
+// options. Hitting the MaxDuration cutoff is not an error: the partial
+// output is returned with TimedOut set.
+func (c *Chain) GenerateWithOptions(opts GenerateOptions) GenerateResult {
+	start := time.Now()
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = start.Add(opts.MaxDuration)
+	}
+	p := make(Prefix, c.prefixLen)
+	for i := range p {
+		p[i] = startToken
+	}
+	if opts.Start != "" {
+		// Shifting each phrase token through the sentinel-filled window
+		// keeps exactly the last prefixLen tokens and leaves short
+		// phrases left-padded, matching Build's seeding. The phrase is
+		// tokenized the way Build tokenized the corpus — runes at
+		// character level — so the seeded key can actually match.
+		for _, w := range c.startTokens(opts.Start) {
+			p.Shift(w)
+		}
+		if len(c.chain[p.String()]) == 0 {
+			c.restartRandom(p)
+		}
+	}
+	budget := opts.MaxSteps
+	if budget <= 0 {
+		budget = opts.Words * stepBudgetFactor
+	}
+	var res GenerateResult
+	var words []string
+	counted, emitted := 0, 0
+	last := ""
+	if opts.Sentences && opts.Start == "" {
+		// The chosen start's own words lead the output and seed the
+		// window, so the walk continues the sentence they open.
+		for _, w := range strings.Fields(c.pickSentenceStart()) {
+			if opts.Sink != nil {
+				if res.WriteErr = writeWord(opts.Sink, c.joinerBefore(w), w, emitted); res.WriteErr != nil {
+					break
+				}
+			} else {
+				words = append(words, w)
+			}
+			emitted++
+			if opts.CountPunct || !punctOnly(w) {
+				counted++
+			}
+			if opts.OnWord != nil {
+				opts.OnWord(w)
+			}
+			p.Shift(w)
+			last = w
+		}
+	}
+	for steps := 0; res.WriteErr == nil; steps++ {
+		if counted >= opts.Words {
+			// Sentence mode overshoots the budget to finish the
+			// sentence, but never past twice the word target.
+			if !opts.Sentences || endsSentence(last) || emitted >= 2*opts.Words {
+				break
+			}
+		}
+		// The step budget bounds total sampling work even when steps
+		// stop mapping one-to-one onto emitted words.
+		if steps >= budget {
+			res.StoppedEarly = "step budget"
+			break
+		}
+		// Deadline checks are amortized: one time.Now call per
+		// deadlineCheckEvery steps instead of per step.
+		if opts.MaxDuration > 0 && steps > 0 && steps%deadlineCheckEvery == 0 && time.Now().After(deadline) {
+			res.TimedOut = true
+			res.StoppedEarly = "deadline"
+			break
+		}
+		key := p.String()
+		choices := c.chain[key]
+		fromBackoff := false
+		if len(choices) == 0 && opts.Backoff {
+			if choices = c.backoffChoices(p); len(choices) == 0 && len(c.chain) > 0 {
+				// The last rung of the ladder. The restart consumes a
+				// step, so the budget bounds how often it can fire.
+				c.restartRandom(p)
+				continue
+			}
+			fromBackoff = true
+		}
+		if len(choices) == 0 {
+			// A seeded start that dead-ends says nothing about the
+			// model; only the canonical start prefix going missing does.
+			if emitted == 0 && len(c.chain) > 0 && opts.Start == "" {
+				res.SuspectCorrupt = true
+			}
+			break
+		}
+		if opts.TopK > 0 {
+			choices = topKCounts(choices, opts.TopK)
+		}
+		var next string
+		if temp := opts.TemperatureFor(emitted); temp != 1 {
+			// Reshaped draws bypass the sample cache: its frozen
+			// tables hold the untransformed cumulative weights.
+			next = weightedChoiceT(c.rng, choices, temp)
+		} else if fromBackoff || opts.TopK > 0 {
+			// Backoff and trimmed tables also bypass the cache: the
+			// frozen tables index the full distributions under their
+			// full keys.
+			next = weightedChoice(c.rng, choices)
+		} else {
+			next = c.choose(key, choices)
+		}
+		// A sentinel that leaked into a model's suffixes (hand-edited
+		// files) advances the walk but never reaches the output.
+		if next == startToken {
+			p.Shift(next)
+			continue
+		}
+		if opts.Sink != nil {
+			if res.WriteErr = writeWord(opts.Sink, c.joinerBefore(next), next, emitted); res.WriteErr != nil {
+				break
+			}
+		} else {
+			words = append(words, next)
+		}
+		emitted++
+		if opts.CountPunct || !punctOnly(next) {
+			counted++
+		}
+		if opts.OnWord != nil {
+			opts.OnWord(next)
+		}
+		p.Shift(next)
+		last = next
+	}
+	if opts.Sink != nil {
+		res.Words, res.Emitted = emitted, emitted
+	} else {
+		res.Text = c.joinTokens(words)
+		res.Words = len(strings.Fields(res.Text))
+		res.Emitted = len(words)
+	}
+	res.Elapsed = time.Since(start)
+	return res
+}
+
+// writeWord appends one word to the sink, preceded by the joiner for
+// every word after the first.
+func writeWord(w io.Writer, joinWith, word string, emitted int) error {
+	if emitted > 0 {
+		if _, err := io.WriteString(w, joinWith); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, word)
+	return err
+}
+
+// GenerateTo streams up to n generated words into w through a buffered
+// writer, so very long outputs appear progressively and never exist as
+// one giant in-memory string. It returns the number of words emitted
+// and the first write error, if any.
+func (c *Chain) GenerateTo(w io.Writer, n int) (int, error) {
+	bw := bufio.NewWriter(w)
+	res := c.GenerateWithOptions(GenerateOptions{Words: n, Sink: bw})
+	if res.WriteErr != nil {
+		return res.Emitted, res.WriteErr
+	}
+	return res.Emitted, bw.Flush()
+}
+
+// punctOnly reports whether the token carries no letters or digits.
+func punctOnly(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// checkWordAccounting verifies the counting contract after formatting:
+// under the space joiner every emitted token is exactly one
+// whitespace-separated unit of the final text. A violation means a
+// token smuggled whitespace (or nothing) through the formatter.
+func (c *Chain) checkWordAccounting(res GenerateResult) error {
+	// Re-attached punctuation deliberately collapses units, so the
+	// contract only holds for the plain space joiner without it.
+	if c.joinWith != " " || c.splitPunct {
+		return nil
+	}
+	if res.Words != res.Emitted {
+		return fmt.Errorf("word accounting mismatch: %d tokens emitted but %d whitespace-separated units in the output", res.Emitted, res.Words)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package markov
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cyclicChain returns an order-1 chain that can generate forever:
+// "" -> a -> b -> a -> b -> ...
+func cyclicChain() *Chain {
+	c := NewChain(1)
+	c.addSuffix("\"\"", "a", 1)
+	c.addSuffix("a", "b", 1)
+	c.addSuffix("b", "a", 1)
+	return c
+}
+
+func TestMaxDurationCutoff(t *testing.T) {
+	c := cyclicChain()
+	// An artificially slow OnWord forces the deadline to pass long
+	// before the word budget is reached, deterministically.
+	res := c.GenerateWithOptions(GenerateOptions{
+		Words:       100000,
+		MaxDuration: time.Millisecond,
+		OnWord:      func(string) { time.Sleep(200 * time.Microsecond) },
+	})
+	if !res.TimedOut {
+		t.Fatal("TimedOut = false, want true")
+	}
+	if res.Words == 0 {
+		t.Error("no partial output was returned")
+	}
+	if res.Words >= 100000 {
+		t.Errorf("generated %d words, expected the cutoff to stop it early", res.Words)
+	}
+	if res.Elapsed <= 0 {
+		t.Errorf("Elapsed = %v, want > 0", res.Elapsed)
+	}
+}
+
+func TestNoCutoffWithoutMaxDuration(t *testing.T) {
+	c := cyclicChain()
+	res := c.GenerateWithOptions(GenerateOptions{Words: 50})
+	if res.TimedOut {
+		t.Error("TimedOut = true without a MaxDuration cap")
+	}
+	if res.Words != 50 {
+		t.Errorf("generated %d words, want 50", res.Words)
+	}
+}
+
+func TestStepBudgetHaltsRunawayGeneration(t *testing.T) {
+	// A cyclic model with a huge word target stands in for the
+	// pathological configurations (fills, stop-words that never fire)
+	// where steps stop mapping one-to-one onto useful words.
+	c := cyclicChain()
+	res := c.GenerateWithOptions(GenerateOptions{Words: 1 << 20, MaxSteps: 10})
+	if res.Words != 10 {
+		t.Errorf("generated %d words under a 10-step budget", res.Words)
+	}
+	if res.StoppedEarly != "step budget" {
+		t.Errorf("StoppedEarly = %q, want \"step budget\"", res.StoppedEarly)
+	}
+	if res.TimedOut {
+		t.Error("a step-budget stop must not masquerade as a deadline stop")
+	}
+}
+
+func TestStepBudgetDefaultDoesNotBindNormalRuns(t *testing.T) {
+	// Without MaxSteps the derived budget (Words * stepBudgetFactor)
+	// leaves one-step-per-word generation untouched.
+	res := cyclicChain().GenerateWithOptions(GenerateOptions{Words: 50})
+	if res.Words != 50 || res.StoppedEarly != "" {
+		t.Errorf("words = %d, StoppedEarly = %q under the default budget", res.Words, res.StoppedEarly)
+	}
+}
+
+func TestMaxStepsFlag(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "m.model")
+	if err := os.WriteFile(model, []byte("1\n\"\"\t\"a\"\t1\na\t\"b\"\t1\nb\t\"a\"\t1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "1000", "-max-steps", "5", "-json")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	var meta struct {
+		Words        int    `json:"words"`
+		StoppedEarly string `json:"stoppedEarly"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Words != 5 || meta.StoppedEarly != "step budget" {
+		t.Errorf("metadata = %+v, want 5 words stopped by the step budget", meta)
+	}
+
+	if code, _, _ := runCLI("generate", "-model", model, "-max-steps", "-1"); code != exitUsage {
+		t.Errorf("negative -max-steps exited %d, want %d", code, exitUsage)
+	}
+}
+
+func TestGenerateJSONMetadata(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("a b a b a b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "10",
+		"-max-time", "1s", "-json")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	var meta struct {
+		Text     string `json:"text"`
+		Words    int    `json:"words"`
+		Elapsed  string `json:"elapsed"`
+		TimedOut bool   `json:"timedOut"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &meta); err != nil {
+		t.Fatalf("stdout is not JSON metadata: %v\nstdout: %q", err, stdout)
+	}
+	if meta.Words == 0 || meta.Text == "" {
+		t.Errorf("metadata has no output: %+v", meta)
+	}
+	if _, err := time.ParseDuration(meta.Elapsed); err != nil {
+		t.Errorf("elapsed %q is not a duration: %v", meta.Elapsed, err)
+	}
+	if meta.TimedOut {
+		t.Error("timedOut = true for a run far under the cap")
+	}
+}
+
+// fourCycleChain returns an order-2 chain over "one two three four"
+// where every key has exactly one suffix, so continuations are fully
+// deterministic.
+func fourCycleChain() *Chain {
+	c := NewChain(2)
+	c.Build(strings.NewReader(strings.Repeat("one two three four ", 10)))
+	return c
+}
+
+func TestGenerateFrom(t *testing.T) {
+	c := fourCycleChain()
+	if got := c.GenerateFrom("one two", 3); got != "one two three four one" {
+		t.Errorf("GenerateFrom(\"one two\", 3) = %q", got)
+	}
+	// A phrase shorter than the prefix length is left-padded with the
+	// sentinel, the same window Build sees after one word.
+	if got := c.GenerateFrom("one", 2); got != "one two three" {
+		t.Errorf("GenerateFrom(\"one\", 2) = %q", got)
+	}
+}
+
+func TestGenerateFromUnknownPhraseRestarts(t *testing.T) {
+	c := fourCycleChain()
+	rand.Seed(1)
+	got := c.GenerateFrom("purple elephant", 4)
+	if fields := strings.Fields(got); len(fields) != 6 {
+		t.Errorf("GenerateFrom on an unknown phrase = %q, want the phrase plus 4 words", got)
+	}
+	if !strings.HasPrefix(got, "purple elephant ") {
+		t.Errorf("output %q does not lead with the phrase", got)
+	}
+}
+
+func TestGenerateFromDeadEndReturnsPhrase(t *testing.T) {
+	if got := NewChain(2).GenerateFrom("hello there", 5); got != "hello there" {
+		t.Errorf("GenerateFrom on an empty chain = %q, want the phrase back", got)
+	}
+}
+
+func TestGenerateStartFlag(t *testing.T) {
+	model := writeTestModel(t)
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "3", "-start", "tick")
+	if code != 0 {
+		t.Fatalf("generate -start exited %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "tick tock") {
+		t.Errorf("output %q does not continue from the start phrase", stdout)
+	}
+}
+
+func TestSeedFlagDeterminism(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("a b a c a d ", 30)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	run := func(seed string) string {
+		t.Helper()
+		code, stdout, stderr := runCLI("generate", "-model", model, "-words", "200", "-seed", seed)
+		if code != 0 {
+			t.Fatalf("generate -seed %s exited %d: %s", seed, code, stderr)
+		}
+		return stdout
+	}
+	first := run("42")
+	if again := run("42"); again != first {
+		t.Errorf("two runs with -seed 42 diverge:\n%q\n%q", first, again)
+	}
+	if other := run("43"); other == first {
+		t.Error("-seed 43 reproduced the -seed 42 output")
+	}
+	// The flag must reach the same generator the library seeds, so
+	// golden expectations can be computed in-process.
+	c, err := loadModelFile(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rand.Seed(42)
+	if want := c.Generate(200); strings.TrimSpace(first) != want {
+		t.Errorf("CLI output with -seed 42 differs from the library's:\n%q\n%q", first, want)
+	}
+}
+
+func TestGenerateToMatchesGenerate(t *testing.T) {
+	c := cyclicChain()
+	rand.Seed(9)
+	want := c.Generate(25)
+	rand.Seed(9)
+	var sb strings.Builder
+	n, err := c.GenerateTo(&sb, 25)
+	if err != nil {
+		t.Fatalf("GenerateTo: %v", err)
+	}
+	if n != 25 {
+		t.Errorf("GenerateTo emitted %d words, want 25", n)
+	}
+	if sb.String() != want {
+		t.Errorf("streamed output %q differs from Generate's %q", sb.String(), want)
+	}
+}
+
+// failAfterWriter accepts the first n bytes and then fails every write.
+type failAfterWriter struct {
+	n       int
+	written int
+}
+
+func (w *failAfterWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.n {
+		return 0, fmt.Errorf("write quota of %d bytes exhausted", w.n)
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestGenerateToPropagatesWriteErrors(t *testing.T) {
+	c := cyclicChain()
+	// The chain produces one-letter words, so a tiny quota fails fast;
+	// the word count must reflect only what was accepted.
+	n, err := c.GenerateTo(&failAfterWriter{n: 3}, 100000)
+	if err == nil {
+		t.Fatal("no error from a failing writer")
+	}
+	if n >= 100000 {
+		t.Errorf("emitted count %d ignores the write failure", n)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/yuxuanwu17/02601-hw1-mark
+
+go 1.21
@@ -0,0 +1,251 @@
+package markov
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Directory inputs are walked into their files so a whole project can
+// be trained on in one invocation, with -skip pruning what never
+// belonged in a corpus (build output, vendored trees) and
+// -tokenizer-map choosing a named cleaning pipeline per file extension.
+
+// tokenPipelines are the named per-token cleaners -tokenizer-map can
+// assign to an extension. A nil cleaner is the generic whitespace
+// pipeline; a cleaner returning "" drops the token.
+var tokenPipelines = map[string]func(token string) string{
+	"ws":       nil,
+	"markdown": cleanMarkdownToken,
+}
+
+// mdLink matches an inline markdown link so the target can be dropped
+// and only the link text trained on.
+var mdLink = regexp.MustCompile(`^\[([^\]]*)\]\([^)]*\)$`)
+
+// cleanMarkdownToken strips markdown syntax from one token: emphasis
+// and code markers are trimmed, link targets are dropped, and tokens
+// that are pure syntax (heading markers, rules, quote markers) vanish.
+func cleanMarkdownToken(tok string) string {
+	if m := mdLink.FindStringSubmatch(tok); m != nil {
+		tok = m[1]
+	}
+	tok = strings.Trim(tok, "*_`~")
+	if strings.Trim(tok, "#*_`~->=") == "" {
+		return ""
+	}
+	return tok
+}
+
+// parseTokenizerMap parses the -tokenizer-map value, comma-separated
+// ".ext=pipeline" entries, into an extension-to-pipeline-name map.
+func parseTokenizerMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		ext, name, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(ext, ".") {
+			return nil, fmt.Errorf("tokenizer map entry %q must be .ext=pipeline", entry)
+		}
+		if _, ok := tokenPipelines[name]; !ok {
+			names := make([]string, 0, len(tokenPipelines))
+			for n := range tokenPipelines {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown tokenizer pipeline %q (want one of %s)", name, strings.Join(names, ", "))
+		}
+		m[strings.ToLower(ext)] = name
+	}
+	return m, nil
+}
+
+// IngestOptions controls how BuildFromInputs turns the named inputs
+// into training text. The zero value ingests plain files exactly as
+// BuildFromFiles always has.
+type IngestOptions struct {
+	Continuous bool              // carry the rolling prefix across files
+	FailFast   bool              // stop at the first failed input
+	Skip       []string          // gitignore-style patterns pruned inside directory inputs
+	Tokenizers map[string]string // file extension -> tokenPipelines name
+	Jobs       int               // files trained in parallel; 0 or 1 is sequential
+}
+
+// BuildFromInputs trains the chain from the named inputs, walking
+// directories into their files (lexical order, Skip patterns pruned)
+// and running each file's tokens through the pipeline its extension is
+// mapped to. Failures accumulate into the returned BuildErrors exactly
+// as in BuildFromFiles.
+func (c *Chain) BuildFromInputs(inputs []string, opts IngestOptions) *BuildErrors {
+	skip := &skipList{patterns: opts.Skip}
+	if len(opts.Skip) == 0 {
+		skip = nil
+	}
+	if opts.Jobs > 1 && !opts.Continuous {
+		return c.buildParallel(inputs, skip, opts)
+	}
+	var errs BuildErrors
+	var carry Prefix
+	stopped := false
+	for _, name := range inputs {
+		files, err := expandInput(name, skip)
+		if err != nil {
+			errs.attempted++
+			errs.add(name, BuildErrOpen, err)
+			if opts.FailFast {
+				break
+			}
+			continue
+		}
+		for _, file := range files {
+			errs.attempted++
+			clean := tokenPipelines[opts.Tokenizers[strings.ToLower(filepath.Ext(file))]]
+			if err := c.buildFromFile(file, clean, opts.Continuous, &carry); err != nil {
+				var be *BuildError
+				if bErr, ok := err.(*BuildError); ok {
+					be = bErr
+				} else {
+					be = &BuildError{Input: file, Category: BuildErrRead, Err: err}
+				}
+				errs.Errs = append(errs.Errs, *be)
+				if opts.FailFast {
+					stopped = true
+					break
+				}
+			}
+		}
+		if stopped {
+			break
+		}
+	}
+	if len(errs.Errs) == 0 {
+		return nil
+	}
+	return &errs
+}
+
+// buildParallel is BuildFromInputs' concurrent path: every file is
+// trained into its own fresh chain by a bounded worker pool, and the
+// local chains are merged in worklist order — so the final counts do
+// not depend on which worker finished first. Each file still starts
+// from an empty prefix, exactly as the sequential path does.
+func (c *Chain) buildParallel(inputs []string, skip *skipList, opts IngestOptions) *BuildErrors {
+	var errs BuildErrors
+	var files []string
+	for _, name := range inputs {
+		expanded, err := expandInput(name, skip)
+		if err != nil {
+			errs.attempted++
+			errs.add(name, BuildErrOpen, err)
+			if opts.FailFast {
+				return &errs
+			}
+			continue
+		}
+		files = append(files, expanded...)
+	}
+	type result struct {
+		chain *Chain
+		err   error
+	}
+	results := make([]result, len(files))
+	sem := make(chan struct{}, opts.Jobs)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			local := c.emptyClone()
+			clean := tokenPipelines[opts.Tokenizers[strings.ToLower(filepath.Ext(file))]]
+			results[i] = result{chain: local, err: local.buildFromFile(file, clean, false, nil)}
+		}(i, file)
+	}
+	wg.Wait()
+	for i, file := range files {
+		errs.attempted++
+		if err := results[i].err; err != nil {
+			be, ok := err.(*BuildError)
+			if !ok {
+				be = &BuildError{Input: file, Category: BuildErrRead, Err: err}
+			}
+			errs.Errs = append(errs.Errs, *be)
+			if opts.FailFast {
+				break
+			}
+			continue
+		}
+		// The workers built into clones of c, so the merge cannot be
+		// incompatible.
+		if err := c.Merge(results[i].chain); err != nil {
+			errs.Errs = append(errs.Errs, BuildError{Input: file, Category: BuildErrRead, Err: err})
+		}
+	}
+	if len(errs.Errs) == 0 {
+		return nil
+	}
+	return &errs
+}
+
+// emptyClone returns a fresh chain with c's configuration (prefix
+// length, tokenizer, joiner) and none of its data, for per-file worker
+// builds.
+func (c *Chain) emptyClone() *Chain {
+	clone := NewChain(c.prefixLen)
+	clone.joinWith = c.joinWith
+	clone.splitOn = c.splitOn
+	clone.splitRe = c.splitRe
+	clone.lowercase = c.lowercase
+	clone.splitPunct = c.splitPunct
+	clone.charLevel = c.charLevel
+	if c.starts != nil {
+		clone.TrackSentenceStarts()
+	}
+	if c.reverse != nil {
+		clone.TrackReverse()
+	}
+	return clone
+}
+
+// expandInput resolves one command-line input: a plain file stays
+// itself, a directory is walked into its regular files with skipped
+// subtrees pruned. Paths come back in the walk's lexical order.
+func expandInput(name string, skip *skipList) ([]string, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{name}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, relErr := filepath.Rel(name, path)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && skip.match(rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,196 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fixtureTree lays out a small project directory for ingestion tests.
+func fixtureTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDirectoryIngestionHonorsSkipPatterns(t *testing.T) {
+	root := fixtureTree(t, map[string]string{
+		"keep.txt":                "alpha beta",
+		"sub/deep/more.txt":       "gamma delta",
+		"dist/app.min.js":         "minified garbage",
+		"node_modules/pkg/x.txt":  "vendored noise",
+		"node_modules/other/y.js": "more noise",
+	})
+	c := NewChain(1)
+	if errs := c.BuildFromInputs([]string{root}, IngestOptions{Skip: []string{"node_modules/**", "*.min.js"}}); errs != nil {
+		t.Fatal(errs)
+	}
+	// Exactly the unskipped files: their words are present, the skipped
+	// files' words are not.
+	want := NewChain(1)
+	for _, f := range []string{"keep.txt", "sub/deep/more.txt"} {
+		fi, err := os.Open(filepath.Join(root, filepath.FromSlash(f)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Build(fi)
+		fi.Close()
+	}
+	if !c.Equal(want) {
+		t.Errorf("ingested chain %v, want exactly the unskipped files' chain %v", c.chain, want.chain)
+	}
+	for _, skipped := range []string{"minified", "vendored", "noise"} {
+		if _, ok := c.chain[skipped]; ok {
+			t.Errorf("skipped word %q was ingested", skipped)
+		}
+	}
+}
+
+func TestTokenizerMapAppliesPerExtension(t *testing.T) {
+	root := fixtureTree(t, map[string]string{
+		"notes.md":   "# Heading\n*hello* [link](http://example.com) world\n",
+		"server.log": "plain log words",
+	})
+	c := NewChain(1)
+	opts := IngestOptions{Tokenizers: map[string]string{".md": "markdown"}}
+	if errs := c.BuildFromInputs([]string{root}, opts); errs != nil {
+		t.Fatal(errs)
+	}
+	// The markdown pipeline unwraps emphasis and links and drops the
+	// bare heading marker; the .log file is tokenized generically.
+	for _, cleaned := range []string{"hello", "link", "world", "plain", "log"} {
+		if _, ok := c.chain[cleaned]; !ok && cleaned != "world" {
+			t.Errorf("expected token %q missing from the chain", cleaned)
+		}
+	}
+	for _, raw := range []string{"#", "*hello*", "[link](http://example.com)"} {
+		if _, ok := c.chain[raw]; ok {
+			t.Errorf("raw markdown token %q leaked into the chain", raw)
+		}
+	}
+}
+
+func TestParseTokenizerMap(t *testing.T) {
+	m, err := parseTokenizerMap(".md=markdown, .log=ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[".md"] != "markdown" || m[".log"] != "ws" {
+		t.Errorf("parsed %v", m)
+	}
+	if _, err := parseTokenizerMap("md=markdown"); err == nil {
+		t.Error("entry without a leading dot parsed")
+	}
+	if _, err := parseTokenizerMap(".md=nope"); err == nil {
+		t.Error("unknown pipeline name parsed")
+	}
+}
+
+func TestReadModeSkipAndTokenizerFlags(t *testing.T) {
+	root := fixtureTree(t, map[string]string{
+		"a.txt":              "one two three",
+		"node_modules/b.txt": "junk junk junk",
+	})
+	out := filepath.Join(t.TempDir(), "m.model")
+	code, _, stderr := runCLI("read", "-prefix", "1", "-skip", "node_modules/**", "-out", out, root)
+	if code != 0 {
+		t.Fatalf("read on a directory exited %d: %s", code, stderr)
+	}
+	model, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(model), "junk") {
+		t.Error("skipped subtree leaked into the model")
+	}
+	if !strings.Contains(string(model), "two") {
+		t.Error("directory file was not ingested")
+	}
+
+	if code, _, _ := runCLI("read", "-tokenizer-map", ".md=nope", "-out", out, root); code != exitUsage {
+		t.Errorf("bad -tokenizer-map exited %d, want %d", code, exitUsage)
+	}
+}
+
+func TestParallelBuildMatchesSequential(t *testing.T) {
+	files := make(map[string]string)
+	sentences := []string{
+		"the cat sat on the mat",
+		"the dog ran over the hill",
+		"a bird flew over the cat",
+		"the mat was warm in the sun",
+	}
+	for i, s := range sentences {
+		files[filepath.Join("docs", string(rune('a'+i))+".txt")] = strings.Repeat(s+" ", 20)
+	}
+	root := fixtureTree(t, files)
+
+	seq := NewChain(2)
+	if errs := seq.BuildFromInputs([]string{root}, IngestOptions{}); errs != nil {
+		t.Fatal(errs)
+	}
+	par := NewChain(2)
+	if errs := par.BuildFromInputs([]string{root}, IngestOptions{Jobs: 4}); errs != nil {
+		t.Fatal(errs)
+	}
+	if !par.Equal(seq) {
+		t.Errorf("parallel build differs from sequential:\npar %v\nseq %v", par.chain, seq.chain)
+	}
+}
+
+func TestParallelBuildAggregatesFailures(t *testing.T) {
+	root := fixtureTree(t, map[string]string{
+		"good.txt": "alpha beta gamma",
+		"bin.dat":  "x\x00y",
+	})
+	c := NewChain(1)
+	errs := c.BuildFromInputs([]string{root}, IngestOptions{Jobs: 3})
+	if errs == nil || len(errs.Errs) != 1 || errs.Errs[0].Category != BuildErrDecode {
+		t.Fatalf("parallel build errors = %v, want the one binary-file decode failure", errs)
+	}
+	if _, ok := c.chain["alpha"]; !ok {
+		t.Error("the good file was not merged into the chain")
+	}
+}
+
+func TestJobsFlagConflicts(t *testing.T) {
+	if code, _, _ := runCLI("read", "-jobs", "-1", "-out", "x.model", "in.txt"); code != exitUsage {
+		t.Errorf("negative -jobs exited %d, want %d", code, exitUsage)
+	}
+	if code, _, stderr := runCLI("read", "-jobs", "4", "-continuous", "-out", "x.model", "in.txt"); code != exitUsage || !strings.Contains(stderr, "-continuous") {
+		t.Errorf("-jobs with -continuous exited %d (%s), want a conflict", code, stderr)
+	}
+}
+
+// The benchmark pair shows what the worker pool buys on a many-file
+// corpus; compare ns/op at -jobs 1 and one job per CPU.
+func benchmarkBuildJobs(b *testing.B, jobs int) {
+	root := b.TempDir()
+	content := strings.Repeat("the quick brown fox jumps over the lazy dog ", 2000)
+	for i := 0; i < 16; i++ {
+		if err := os.WriteFile(filepath.Join(root, string(rune('a'+i))+".txt"), []byte(content), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := NewChain(2)
+		if errs := c.BuildFromInputs([]string{root}, IngestOptions{Jobs: jobs}); errs != nil {
+			b.Fatal(errs)
+		}
+	}
+}
+
+func BenchmarkBuildSequential(b *testing.B) { benchmarkBuildJobs(b, 1) }
+func BenchmarkBuildParallel(b *testing.B)   { benchmarkBuildJobs(b, runtime.NumCPU()) }
@@ -0,0 +1,230 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+)
+
+// The JSON model format mirrors everything the text format carries, so
+// models can cross into other tooling and come back without loss. The
+// schema is versioned by shape, not number: every logical section is
+// listed in modelSections below, and the conformance test round-trips
+// a maximally-featured model through every format pair section by
+// section — an exporter that forgets a registered section fails that
+// test by name.
+
+// modelPayload is the serialization schema shared by the JSON and
+// binary formats: JSON marshals it, gob encodes it, and both rebuild a
+// chain through toChain, so the two formats cannot drift apart.
+type modelPayload struct {
+	PrefixLen  int                           `json:"prefixLen"`
+	JoinWith   string                        `json:"joinWith"`
+	SplitOn    string                        `json:"splitOn,omitempty"`
+	Mode       string                        `json:"mode,omitempty"` // "" or "word" for word level, "char" for rune level
+	Lowercase  bool                          `json:"lowercase,omitempty"`
+	SplitPunct bool                          `json:"splitPunct,omitempty"`
+	Stats      map[string]string             `json:"stats,omitempty"`
+	Starts     map[string]float64            `json:"sentenceStarts,omitempty"`
+	Reverse    bool                          `json:"reverse,omitempty"` // rebuild the reverse index on load; see TrackReverse
+	Chain      map[string]map[string]float64 `json:"chain"`
+}
+
+// modelSection is one logical section of a model: a name for
+// diagnostics and an equality predicate over loaded chains.
+type modelSection struct {
+	name  string
+	equal func(a, b *Chain) bool
+}
+
+// modelSections is the registry of everything a model carries. Every
+// exporter and importer must preserve each section; Chain.Equal and
+// the conformance tests iterate this list, so a new section added here
+// is checked everywhere automatically.
+var modelSections = []modelSection{
+	{"prefix-length", func(a, b *Chain) bool { return a.prefixLen == b.prefixLen }},
+	{"joiner", func(a, b *Chain) bool { return a.joinWith == b.joinWith }},
+	{"tokenizer", func(a, b *Chain) bool { return a.splitOn == b.splitOn }},
+	{"normalization", func(a, b *Chain) bool { return a.lowercase == b.lowercase && a.splitPunct == b.splitPunct }},
+	{"level", func(a, b *Chain) bool { return a.charLevel == b.charLevel }},
+	{"stats", func(a, b *Chain) bool { return len(a.stats)+len(b.stats) == 0 || reflect.DeepEqual(a.stats, b.stats) }},
+	{"sentence-starts", func(a, b *Chain) bool {
+		return len(a.starts)+len(b.starts) == 0 || reflect.DeepEqual(a.starts, b.starts)
+	}},
+	{"reverse", func(a, b *Chain) bool {
+		return len(a.reverse)+len(b.reverse) == 0 || reflect.DeepEqual(a.reverse, b.reverse)
+	}},
+	{"transitions", func(a, b *Chain) bool { return reflect.DeepEqual(a.chain, b.chain) }},
+}
+
+// Equal reports whether both chains carry identical data in every
+// registered section.
+func (c *Chain) Equal(o *Chain) bool {
+	for _, s := range modelSections {
+		if !s.equal(c, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// payload gathers every registered section into the shared schema.
+func (c *Chain) payload() modelPayload {
+	mode := ""
+	if c.charLevel {
+		mode = "char"
+	}
+	return modelPayload{
+		PrefixLen:  c.prefixLen,
+		JoinWith:   c.joinWith,
+		SplitOn:    c.splitOn,
+		Mode:       mode,
+		Lowercase:  c.lowercase,
+		SplitPunct: c.splitPunct,
+		Stats:      c.stats,
+		Starts:     c.starts,
+		Reverse:    c.reverse != nil,
+		Chain:      c.chain,
+	}
+}
+
+// toChain rebuilds a chain from a decoded payload, validating the same
+// invariants the text loader enforces.
+func (m modelPayload) toChain() (*Chain, error) {
+	c, err := m.headerChain()
+	if err != nil {
+		return nil, err
+	}
+	for key, counts := range m.Chain {
+		for word, n := range counts {
+			if err := validWeight(n); err != nil {
+				return nil, fmt.Errorf("%v for suffix %q of prefix %q", err, word, key)
+			}
+			c.addSuffix(key, word, n)
+		}
+	}
+	if len(c.chain) == 0 {
+		return nil, ErrEmptyChain
+	}
+	return c, nil
+}
+
+// validWeight rejects the weights no suffix may carry.
+func validWeight(n float64) error {
+	if n <= 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return fmt.Errorf("bad count %v", n)
+	}
+	return nil
+}
+
+// headerChain rebuilds everything but the transitions, for loaders
+// that stream the chain data in their own shape.
+func (m modelPayload) headerChain() (*Chain, error) {
+	if m.PrefixLen < 1 {
+		return nil, fmt.Errorf("model has prefix length %d", m.PrefixLen)
+	}
+	c := NewChain(m.PrefixLen)
+	c.joinWith = m.JoinWith
+	c.lowercase = m.Lowercase
+	c.splitPunct = m.SplitPunct
+	switch m.Mode {
+	case "", "word":
+	case "char":
+		c.charLevel = true
+	default:
+		return nil, fmt.Errorf("unknown model mode %q (want word or char)", m.Mode)
+	}
+	if m.Reverse {
+		c.TrackReverse()
+	}
+	for name, value := range m.Stats {
+		c.setStat(name, value)
+	}
+	for key, n := range m.Starts {
+		if n <= 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("bad sentence-start count %v for key %q", n, key)
+		}
+		c.addSentenceStart(key, n)
+	}
+	if m.SplitOn != "" {
+		if err := c.SetTokenizer(m.SplitOn); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// writeJSONModel exports the chain as one indented JSON object.
+func (c *Chain) writeJSONModel(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.payload())
+}
+
+// readJSONModel imports a chain from the JSON schema.
+func readJSONModel(r io.Reader) (*Chain, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var m modelPayload
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("bad JSON model: %v", err)
+	}
+	return m.toChain()
+}
+
+// isJSONModel reports whether the file plausibly holds the JSON
+// schema: its first non-space byte is an opening brace.
+func isJSONModel(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		default:
+			return b == '{'
+		}
+	}
+}
+
+// loadModelFile loads a binary, JSON, or text model, deciding by
+// content.
+func loadModelFile(path string) (*Chain, error) {
+	switch {
+	case isBinModel(path):
+		return loadStreamModel(path, LoadChain)
+	case isJSONModel(path):
+		return loadStreamModel(path, readJSONModel)
+	}
+	return loadTextChain(path)
+}
+
+// loadStreamModel opens the file and hands it to one of the stream
+// readers, mapping failures onto the usual typed errors.
+func loadStreamModel(path string, read func(io.Reader) (*Chain, error)) (*Chain, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &notFoundError{what: "model", path: path, err: err}
+	}
+	defer f.Close()
+	c, err := read(f)
+	if err != nil {
+		if err == ErrEmptyChain {
+			return nil, fmt.Errorf("model %s: %w", path, ErrEmptyChain)
+		}
+		return nil, &corruptError{path: path, msg: err.Error()}
+	}
+	return c, nil
+}
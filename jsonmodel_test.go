@@ -0,0 +1,145 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// maximalChain builds a model with every optional feature set: a
+// non-default order, joiner, and tokenizer, fractional weights, a
+// stats section, tracked sentence starts, and a reverse index.
+// Conformance tests round-trip it through every format pair.
+func maximalChain(t *testing.T) *Chain {
+	t.Helper()
+	c := NewChain(2)
+	c.SetJoiner("\n")
+	if err := c.SetTokenizer(`/`); err != nil {
+		t.Fatal(err)
+	}
+	c.TrackSentenceStarts()
+	c.TrackReverse()
+	c.SetLowercase(true)
+	c.SetSplitPunct(true)
+	c.Build(strings.NewReader("spring/rain 2020/again\nspring/rain/falls"))
+	c.addSuffix("rain falls", "softly", 2.5) // fractional, from external adjustment
+	c.setStat("terminators", `{"falls.":1}`)
+	return c
+}
+
+// reload runs one export/import pair and returns the loaded chain.
+func reload(t *testing.T, c *Chain, write func(io.Writer) error, read func(io.Reader) (*Chain, error)) *Chain {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestModelSectionConformance(t *testing.T) {
+	c := maximalChain(t)
+	readText := func(r io.Reader) (*Chain, error) {
+		sc := bufio.NewScanner(r)
+		if !sc.Scan() {
+			t.Fatal("empty text model")
+		}
+		prefixLen, err := strconv.Atoi(strings.TrimSpace(sc.Text()))
+		if err != nil {
+			return nil, err
+		}
+		loaded := NewChain(prefixLen)
+		return loaded, loaded.BuildFromRead(sc, prefixLen)
+	}
+	trips := map[string]*Chain{
+		"json":           reload(t, c, c.writeJSONModel, readJSONModel),
+		"text":           reload(t, c, c.writeModel, readText),
+		"bin":            reload(t, c, c.Save, LoadChain),
+		"json-text-json": nil, // filled below
+	}
+	viaJSON := reload(t, c, c.writeJSONModel, readJSONModel)
+	trips["json-text-json"] = reload(t, reload(t, viaJSON, viaJSON.writeModel, readText), viaJSON.writeJSONModel, readJSONModel)
+
+	for trip, got := range trips {
+		// Per registered section, so a forgotten section fails by name.
+		for _, s := range modelSections {
+			if !s.equal(c, got) {
+				t.Errorf("%s round trip lost section %q", trip, s.name)
+			}
+		}
+		if !c.Equal(got) {
+			t.Errorf("%s round trip: Chain.Equal is false", trip)
+		}
+	}
+}
+
+func TestChainEqualDetectsEverySectionDifference(t *testing.T) {
+	base := maximalChain(t)
+	mutations := map[string]func(*Chain){
+		"prefix-length":   func(c *Chain) { c.prefixLen++ },
+		"joiner":          func(c *Chain) { c.joinWith = "|" },
+		"tokenizer":       func(c *Chain) { c.splitOn = "," },
+		"normalization":   func(c *Chain) { c.splitPunct = false },
+		"level":           func(c *Chain) { c.charLevel = true },
+		"stats":           func(c *Chain) { c.setStat("terminators", "{}") },
+		"sentence-starts": func(c *Chain) { c.addSentenceStart("rain falls", 1) },
+		"reverse":         func(c *Chain) { c.reverse = nil },
+		"transitions":     func(c *Chain) { c.addSuffix("rain falls", "hard", 1) },
+	}
+	if len(mutations) != len(modelSections) {
+		t.Fatalf("test covers %d sections, registry has %d — update this test with the new section", len(mutations), len(modelSections))
+	}
+	for name, mutate := range mutations {
+		other := maximalChain(t)
+		mutate(other)
+		if base.Equal(other) {
+			t.Errorf("Equal missed a difference in section %q", name)
+		}
+	}
+}
+
+func TestReadJSONModelRejectsMalformed(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not json":      "not json at all",
+		"bad prefixLen": `{"prefixLen":0,"joinWith":" ","chain":{"a":{"b":1}}}`,
+		"bad weight":    `{"prefixLen":1,"joinWith":" ","chain":{"a":{"b":-1}}}`,
+		"unknown field": `{"prefixLen":1,"joinWith":" ","chain":{"a":{"b":1}},"extra":true}`,
+		"empty chain":   `{"prefixLen":1,"joinWith":" ","chain":{}}`,
+		"bad tokenizer": `{"prefixLen":1,"joinWith":" ","splitOn":"(","chain":{"a":{"b":1}}}`,
+	} {
+		if _, err := readJSONModel(strings.NewReader(doc)); err == nil {
+			t.Errorf("readJSONModel accepted %s", name)
+		}
+	}
+}
+
+func TestJSONModelCLIRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("tick tock ", 30)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.json")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-format", "json", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if !isJSONModel(model) {
+		t.Fatal("written model is not detected as JSON")
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "10")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output from the JSON model")
+	}
+}
@@ -0,0 +1,99 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The legacy format is the original homework layout this program used
+// before the quoted v2 format: a bare integer first line, then
+// space-separated "prefix suffix count suffix count" lines with
+// suffixes in alphabetical order. Classmates' tools still read and
+// write it, so we keep a writer that reproduces it byte-for-byte and a
+// loader that detects it. Two deliberate differences from the original
+// writer: keys are emitted in sorted order (the original iterated a
+// map, so its order was never stable to begin with), and the counts
+// are correct — the original's run-length loop never reset its
+// counter, inflating every count after the first repeated suffix.
+// -bug-compatible reproduces the inflated counts for tools that
+// round-trip them.
+
+// writeLegacyModel writes the chain in the legacy layout. Weights must
+// be integral: the format predates fractional counts.
+func (c *Chain) writeLegacyModel(w io.Writer, bugCompatible bool) error {
+	if _, err := fmt.Fprintln(w, c.prefixLen); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(c.chain))
+	for key := range c.chain {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		counts := c.chain[key]
+		suffixes := make([]string, 0, len(counts))
+		for s := range counts {
+			if n := counts[s]; n != math.Trunc(n) {
+				return fmt.Errorf("legacy format cannot express fractional weight %v for suffix %q", n, s)
+			}
+			suffixes = append(suffixes, s)
+		}
+		sort.Strings(suffixes)
+		var b strings.Builder
+		b.WriteString(key)
+		// The original counted runs over the expanded, sorted suffix
+		// list without resetting the counter between runs.
+		runningCount := 1
+		for _, s := range suffixes {
+			n := int(counts[s])
+			if bugCompatible && len(suffixes) > 1 {
+				runningCount += n - 1
+				n = runningCount
+			}
+			fmt.Fprintf(&b, " %s %d", s, n)
+		}
+		if _, err := fmt.Fprintln(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLegacyModelLines parses legacy data lines (first is the already
+// peeked line after the header) into the chain.
+func (c *Chain) readLegacyModelLines(scanner *bufio.Scanner, first string) error {
+	lineNum := 1 // the header was line 1
+	apply := func(line string) error {
+		lineNum++
+		if line == "" {
+			return nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) < c.prefixLen+2 || (len(fields)-c.prefixLen)%2 != 0 {
+			return &corruptError{line: lineNum, msg: fmt.Sprintf("legacy line has %d fields, want prefix of %d plus suffix/count pairs", len(fields), c.prefixLen)}
+		}
+		key := strings.Join(fields[:c.prefixLen], " ")
+		for i := c.prefixLen; i < len(fields); i += 2 {
+			n, err := strconv.Atoi(fields[i+1])
+			if err != nil || n < 1 {
+				return &corruptError{line: lineNum, msg: fmt.Sprintf("bad legacy count %q for suffix %q", fields[i+1], fields[i])}
+			}
+			c.addSuffix(key, fields[i], float64(n))
+		}
+		return nil
+	}
+	if err := apply(first); err != nil {
+		return err
+	}
+	for scanner.Scan() {
+		if err := apply(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,125 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLegacyWriterGolden(t *testing.T) {
+	c := NewChain(2)
+	c.Build(strings.NewReader("I am not a number! I am a free man!"))
+	var buf strings.Builder
+	if err := c.writeLegacyModel(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "legacy.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("legacy output differs from golden:\ngot:\n%swant:\n%s", buf.String(), want)
+	}
+}
+
+func TestLegacyWriterBugCompatibleGolden(t *testing.T) {
+	// The original writer never reset its run counter, so every count
+	// after the first repeated suffix was inflated: "the" is followed
+	// by cat twice and dog once, but the original wrote "dog 2".
+	c := NewChain(1)
+	c.Build(strings.NewReader("the cat and the cat and the dog"))
+	var buf strings.Builder
+	if err := c.writeLegacyModel(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "legacy-bug.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("bug-compatible output differs from golden:\ngot:\n%swant:\n%s", buf.String(), want)
+	}
+}
+
+func TestLegacyWriterRejectsFractionalWeights(t *testing.T) {
+	c := NewChain(1)
+	c.addSuffix("a", "b", 2.5)
+	if err := c.writeLegacyModel(&strings.Builder{}, false); err == nil {
+		t.Error("fractional weight serialized into the legacy format")
+	}
+}
+
+func TestLoaderDetectsBothFormats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// A v2 file whose first data line starts with a numeric key must
+	// not be mistaken for a legacy line: the tab decides.
+	v2 := write("v2.model", "1\n2020\t\"again\"\t3\n")
+	c, err := loadTextChain(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.chain["2020"]["again"] != 3 {
+		t.Errorf("v2 numeric-key model loaded as %v", c.chain)
+	}
+	// The same data in legacy layout.
+	legacy := write("legacy.model", "1\n2020 again 3\n")
+	c, err = loadTextChain(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.chain["2020"]["again"] != 3 {
+		t.Errorf("legacy model loaded as %v", c.chain)
+	}
+	// Malformed legacy pairs are corrupt, with the line number.
+	if _, err := loadTextChain(write("bad.model", "1\na b\n")); err == nil {
+		t.Error("legacy line without a count loaded without error")
+	}
+}
+
+func TestLegacyRoundTripThroughCLI(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("tick tock goes the clock ", 20)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "legacy.model")
+	if code, _, stderr := runCLI("read", "-prefix", "2", "-format", "legacy", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "10")
+	if code != 0 {
+		t.Fatalf("generate from legacy model exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output from the legacy model")
+	}
+	// The loaded legacy chain carries the same transitions as the
+	// chain that wrote it.
+	loaded, err := loadTextChain(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct := NewChain(2)
+	fi, err := os.Open(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct.Build(fi)
+	fi.Close()
+	if !loaded.Equal(direct) {
+		t.Error("legacy round trip lost transitions")
+	}
+
+	if code, _, _ := runCLI("read", "-prefix", "1", "-bug-compatible", "-out", model, corpus); code != exitUsage {
+		t.Errorf("-bug-compatible without -format legacy exited %d, want %d", code, exitUsage)
+	}
+}
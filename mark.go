@@ -43,30 +43,127 @@ Markov chain, and writes generated text to standard output.
 The prefix and output lengths can be specified using the -prefix and -words
 flags on the command-line.
 */
-package main
+package markov
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"os"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Chain contains a map ("chain") of prefixes to a list of suffixes.
+// Chain contains a map ("chain") of prefixes to their suffix counts.
 // A prefix is a string of prefixLen words joined with spaces.
-// A suffix is a single word. A prefix can have multiple suffixes.
+// A suffix is a single word. A prefix can have multiple suffixes, and
+// each suffix carries the number of times it followed the prefix, so
+// common continuations are stored once instead of once per occurrence.
 type Chain struct {
-	chain     map[string][]string
-	prefixLen int
+	chain      map[string]map[string]float64
+	prefixLen  int
+	joinWith   string                        // how generated tokens are joined; " " for prose, "" for char-style output
+	splitOn    string                        // tokenizer pattern source, "" means whitespace splitting
+	splitRe    *regexp.Regexp                // compiled form of splitOn
+	lowercase  bool                          // fold tokens to lower case, see SetLowercase
+	splitPunct bool                          // peel punctuation into its own tokens, see SetSplitPunct
+	charLevel  bool                          // one token per rune, see SetCharLevel
+	samples    *sampleCache                  // lazy frozen sampling tables, nil unless enabled
+	intern     map[string]string             // canonical word instances, see internWord
+	collectors []Collector                   // build statistics observers, see AddCollector
+	stats      map[string]string             // serialized stats sections, carried by the model formats
+	backoff    *backoffIndex                 // lazily derived lower-order tables, see ensureBackoff
+	starts     map[string]float64            // sentence-opening prefix counts, nil unless tracked; see TrackSentenceStarts
+	reverse    map[string]map[string]float64 // backward index, nil unless tracked; see TrackReverse
+	rng        *rand.Rand                    // chain-local draws, see SetRandom; nil shares the global generator
 }
 
+// SetJoiner sets the string placed between generated tokens. It is
+// recorded in the model header so generate mode picks it up again.
+func (c *Chain) SetJoiner(j string) { c.joinWith = j }
+
+// SetRandom gives the chain its own random stream seeded from src, so
+// two chains generating in the same process never perturb each other's
+// draws. Without it the chain draws from the package-global generator
+// (the one Main seeds for the CLI).
+func (c *Chain) SetRandom(src rand.Source) { c.rng = rand.New(src) }
+
+// SetTokenizer makes Build split input on matches of the given regexp
+// instead of whitespace (line breaks always separate tokens). A pattern
+// that matches the empty string is rejected: it would split between
+// every pair of bytes and produce a token per character by accident.
+func (c *Chain) SetTokenizer(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("bad -split-on pattern %q: %v", pattern, err)
+	}
+	if re.FindStringIndex("") != nil {
+		return fmt.Errorf("-split-on pattern %q matches the empty string", pattern)
+	}
+	c.splitOn = pattern
+	c.splitRe = re
+	return nil
+}
+
+// addSuffix records that word followed the prefix key with n more
+// weight. It is Add's key-level core, shared with the model loaders
+// whose input already carries canonical keys. Weights are float64 so
+// externally adjusted models with fractional counts are first-class;
+// Build adds integral weight 1 per occurrence.
+func (c *Chain) addSuffix(key, word string, n float64) {
+	m := c.chain[key]
+	if m == nil {
+		m = make(map[string]float64)
+		c.chain[key] = m
+	}
+	m[c.internWord(word)] += n
+	c.noteReverse(key, word, n)
+}
+
+// Merge folds other's suffix counts into c: counts for identical
+// key/suffix pairs add up, so merging per-file chains in any order
+// yields the same totals a single sequential build would. Chains with
+// different prefix lengths (or at different levels) cannot mix and
+// come back as ErrIncompatibleModels.
+func (c *Chain) Merge(other *Chain) error { return c.MergeWeighted(other, 1) }
+
+// MergeWeighted is Merge with every one of other's counts multiplied
+// by weight first, for blending sources with a bias toward one of
+// them.
+func (c *Chain) MergeWeighted(other *Chain, weight float64) error {
+	if other.prefixLen != c.prefixLen {
+		return &incompatibleModelsError{a: c.prefixLen, b: other.prefixLen}
+	}
+	if other.charLevel != c.charLevel {
+		return fmt.Errorf("%w: one chain is word-level and the other character-level", ErrIncompatibleModels)
+	}
+	if err := validWeight(weight); err != nil {
+		return fmt.Errorf("merge weight: %v", err)
+	}
+	for key, counts := range other.chain {
+		for w, n := range counts {
+			c.addSuffix(key, w, n*weight)
+		}
+	}
+	for key, n := range other.starts {
+		c.addSentenceStart(key, n*weight)
+	}
+	return nil
+}
+
+// startToken is the filler token a fresh prefix is padded with. It is
+// the literal two-character string `""`, never produced by the
+// tokenizer, so it cannot collide with a real word.
+const startToken = "\"\""
+
 // Prefix is a Markov chain prefix of one or more words.
 type Prefix []string
 
@@ -77,237 +174,739 @@ func (p Prefix) String() string {
 }
 
 // Shift removes the first word from the Prefix and appends the given word.
-//The Shift method uses the built-in copy function to copy the last len(p)-1 elements of p to the start of the slice,
+// The Shift method uses the built-in copy function to copy the last len(p)-1 elements of p to the start of the slice,
 // effectively moving the elements one index to the left (if you consider zero as the leftmost index).
 func (p Prefix) Shift(word string) {
 	copy(p, p[1:])
 	p[len(p)-1] = word
 }
 
-//NewChain returns a new Chain with prefixes of prefixLen words.
-//This is a constructor function
+// NewChain returns a new Chain with prefixes of prefixLen words.
+// This is a constructor function
 func NewChain(prefixLen int) *Chain {
-	return &Chain{make(map[string][]string), prefixLen}
+	return &Chain{chain: make(map[string]map[string]float64), prefixLen: prefixLen, joinWith: " "}
 }
 
 // Build reads text from the provided Reader and
 // parses it into prefixes and suffixes that are stored in Chain.
-// The Build method returns once the Reader's Read method returns io.EOF (end of file) or some other read error occurs.
-
-func (c *Chain) Build(r io.Reader) {
-	br := bufio.NewReader(r)       // buffering
-	p := make(Prefix, c.prefixLen) // We'll use this variable to hold the current prefix and mutate it with each new word we encounter.
-	//initialize the p with ""
-	for i := range p {
-		p[i] = "\"\""
-	}
-	for {
-		var s string
-		// fmt.Fscan reads space-separated values from an io.Reader + stops if errors occurred.
-		if _, err := fmt.Fscan(br, &s); err != nil { // use &s is the requirement of the Fscan package
-			break
-		}
-		key := p.String()
-		c.chain[key] = append(c.chain[key], s)
-		p.Shift(s)
+// It returns once the Reader is exhausted; a read failure other than
+// io.EOF comes back as the error instead of being swallowed, with
+// everything read up to that point already in the chain.
+func (c *Chain) Build(r io.Reader) error {
+	tr := &errTrackReader{r: r}
+	_, err := c.buildClean(tr, nil, nil)
+	if tr.err != nil {
+		return tr.err
 	}
+	return err
 }
 
-// 基于指针对象的函数
-// https://docs.hacknode.org/gopl-zh/ch6/ch6-02.html
+// BuildContinue is like Build but starts from the given prefix and
+// returns the prefix left after the last word, so a caller can carry
+// the rolling prefix across several readers (e.g. one work split over
+// chapter files). A nil prefix starts fresh, the same as Build. The
+// error is the tokenizer's (see eachToken).
+func (c *Chain) BuildContinue(r io.Reader, p Prefix) (Prefix, error) {
+	return c.buildClean(r, nil, p)
+}
+
+// buildClean is BuildContinue with an optional per-token cleaner (see
+// tokenPipelines); a cleaner returning "" drops the token.
+func (c *Chain) buildClean(r io.Reader, clean func(string) string, p Prefix) (Prefix, error) {
+	// A carried-in prefix means mid-stream continuation, so only a fresh
+	// start counts as the beginning of a sentence.
+	atStart := p == nil
+	if p == nil {
+		// We'll use this variable to hold the current prefix and mutate it with each new word we encounter.
+		p = make(Prefix, c.prefixLen)
+		//initialize the p with ""
+		for i := range p {
+			p[i] = startToken
+		}
+	}
+	// Each pending sentence start counts down from prefixLen: it hits
+	// zero exactly when the window holds that sentence's opening words,
+	// and the key is recorded then. Sentences shorter than the window
+	// overlap, hence a slice rather than a single countdown.
+	var startsDue []int
+	idx := 0
+	err := c.eachToken(r, func(s string) {
+		if clean != nil {
+			if s = clean(s); s == "" {
+				return
+			}
+		}
+		if c.starts != nil {
+			if atStart {
+				startsDue = append(startsDue, c.prefixLen)
+			}
+			atStart = endsSentence(s)
+		}
+		c.Add(p, s, 1, WithTokenMeta(TokenMeta{Index: idx}))
+		idx++
+		p.Shift(s)
+		for i := 0; i < len(startsDue); {
+			if startsDue[i]--; startsDue[i] == 0 {
+				c.addSentenceStart(p.String(), 1)
+				startsDue = append(startsDue[:i], startsDue[i+1:]...)
+				continue
+			}
+			i++
+		}
+	})
+	return p, err
+}
 
-func (c *Chain) BuildFromRead(scanner *bufio.Scanner, prefixLen int) {
-	p := make(map[string][]string) // We'll use this variable to hold the current prefix and mutate it with each new word we encounter.、
+// maxScanLine is the per-line cap for the line-oriented scanners. A
+// single-line corpus is legal -split-on input, and writeModel puts a
+// prefix's whole suffix set on one line — a hub prefix in an order-1
+// model passes bufio's 64KB default easily — so readers must accept
+// far longer lines than that; the cap still bounds a runaway line
+// instead of growing without limit.
+const maxScanLine = 64 << 20
+
+// eachToken streams the reader through the chain's tokenizer and
+// normalization options, calling fn once per token.
+func (c *Chain) eachToken(r io.Reader, fn func(token string)) error {
+	return eachToken(r, c.splitRe, func(tok string) { c.normalizeToken(tok, fn) })
+}
 
-	// 要以 key - val的形式来储存
-	// key -> string val->[]string
-	// key 为前n个， val 为 后面的两个，去除掉数字的个数
-	count := 0
+// eachToken streams the reader through a tokenizer, calling fn once
+// per token. With a nil pattern it is Fscan-style whitespace splitting;
+// otherwise each line is split on matches of the pattern (line breaks
+// always separate tokens, empty fields are dropped). The returned
+// error is the line scanner's — a read failure, or a line over
+// maxScanLine; the whitespace path reports read failures through
+// errTrackReader instead.
+func eachToken(r io.Reader, splitRe *regexp.Regexp, fn func(token string)) error {
+	if splitRe == nil {
+		br := bufio.NewReader(r) // buffering
+		for {
+			var s string
+			// fmt.Fscan reads space-separated values from an io.Reader + stops if errors occurred.
+			if _, err := fmt.Fscan(br, &s); err != nil { // use &s is the requirement of the Fscan package
+				return nil
+			}
+			fn(s)
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScanLine)
 	for scanner.Scan() {
+		for _, tok := range splitRe.Split(scanner.Text(), -1) {
+			if tok == "" {
+				continue
+			}
+			fn(tok)
+		}
+	}
+	// A scan that stops early without the error surfacing would
+	// silently truncate the training data and report success.
+	return scanner.Err()
+}
 
-		// 变回原来的 c.chain format
-		currentLine := scanner.Text()
-		//fmt.Println(currentLine)
-		key, val := TextLineToChain(currentLine, prefixLen)
-		p[key] = val
-		//
-		count++
+// keyAritySample is how many keys a sampled -validate-keys pass checks.
+const keyAritySample = 1000
+
+// validateKeyArity verifies that keys contain exactly prefixLen
+// space-joined words, catching models whose header disagrees with
+// their keys (hand-edited files, buggy exporters). Wrong-arity keys
+// would make every Generate lookup miss and silently produce tiny
+// output. In sample mode only the first keyAritySample keys seen are
+// checked.
+func (c *Chain) validateKeyArity(full bool) error {
+	checked := 0
+	for key := range c.chain {
+		if got := len(strings.Split(key, " ")); got != c.prefixLen {
+			return &corruptError{msg: fmt.Sprintf("key %q has %d words, expected %d (wrong arity for this prefix length)", key, got, c.prefixLen)}
+		}
+		checked++
+		if !full && checked >= keyAritySample {
+			break
+		}
 	}
-	c.chain = p
+	return nil
+}
+
+// BuildFromRead fills the chain from an already-opened model file whose
+// prefix length header has been consumed. It replaces the old digit-
+// stripping parser: counts are parsed exactly, so the loaded chain has
+// the same suffix distribution the model was written with.
+func (c *Chain) BuildFromRead(scanner *bufio.Scanner, prefixLen int) error {
 	c.prefixLen = prefixLen
+	return c.readModelLines(scanner)
 }
 
-// Generate returns a string of at most n words generated from Chain. It reads words from the map and appends them to a slice (words).
-// n specifies the maximum number of integer input
+// Generate returns a string of at most n words generated from Chain.
+// It is a thin wrapper over GenerateWithOptions for callers that only
+// care about the word budget.
 func (c *Chain) Generate(n int) string {
-	p := make(Prefix, c.prefixLen)
-	var words []string
-	for i := range p {
-		p[i] = "\"\""
-	}
-	for i := 0; i < n; i++ {
-		choices := c.chain[p.String()]
-		if len(choices) == 0 {
-			break
-		} // if there is not enough suffix, break the for loop
-		next := choices[rand.Intn(len(choices))]
-		words = append(words, next)
-		p.Shift(next)
-	}
-	return strings.Join(words, " ")
+	return c.GenerateWithOptions(GenerateOptions{Words: n}).Text
 }
 
-func ValIteration(val []string) string {
-	if len(val) == 1 {
-		return val[0] + " 1"
-	} else {
-		processedVal := ""
-		count := 1
-		sort.Strings(val)
-
-		for i := 0; i < len(val); i++ {
-			if i < len(val)-1 && val[i] == val[i+1] {
-				count++
-			} else {
-				//fmt.Println(count)
-				processedVal = processedVal + " " + val[i] + " " + strconv.Itoa(count)
-			}
-		}
-		return strings.TrimSpace(processedVal)
+// GenerateFrom generates up to n words continuing from a start phrase
+// and returns the phrase followed by the continuation. A phrase that
+// leads nowhere comes back as itself rather than as an empty string.
+func (c *Chain) GenerateFrom(start string, n int) string {
+	res := c.GenerateWithOptions(GenerateOptions{Words: n, Start: start})
+	if res.Text == "" {
+		return start
 	}
+	return start + c.joinWith + res.Text
 }
 
-func TextLineToChain(currentLine string, prefixLen int) (string, []string) {
-
-	// regex
-	reg := regexp.MustCompile(`\D+`)
-	if reg == nil {
-		fmt.Println("MustCompile err")
+// weightedChoice picks one suffix at random with probability
+// proportional to its weight. Suffixes are visited in sorted order so a
+// seeded generator makes reproducible choices; the selection itself is
+// pickWeighted's, so every loader draws identically.
+func weightedChoice(rng *rand.Rand, counts map[string]float64) string {
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
 	}
-	result := reg.FindAllString(currentLine, -1)
+	sort.Strings(words)
+	return words[pickWeighted(rng, len(words), func(i int) float64 { return counts[words[i]] })]
+}
 
-	// back to one string
-	// []int -> string
-	resultOneString := ""
-	for _, s := range result {
-		resultOneString = resultOneString + s
+// Main parses the global flags, dispatches to the selected mode, and
+// maps any failure onto an exit code and a rendered stderr message. It
+// is the whole CLI as a function: cmd/mark hands it os.Args and exits
+// with its return value, and the tests drive it in-process with
+// captured output.
+func Main(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("mark", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	errorFormat := global.String("error-format", "text", `how to render errors on stderr: "text" or "json"`)
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *errorFormat != "text" && *errorFormat != "json" {
+		reportError(stderr, "text", &usageError{msg: `-error-format must be "text" or "json"`})
+		return exitUsage
+	}
+	if err := run(global.Args(), stdout, stderr); err != nil {
+		reportError(stderr, *errorFormat, err)
+		return exitCode(err)
 	}
+	return 0
+}
 
-	//key -> string val->[]string
-	// create the format suitable for key
-	splitStringList := strings.Split(resultOneString, " ")
+// run dispatches to the selected mode. All progress chatter goes to
+// stderr; only program output (generated text) is written to stdout.
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return &usageError{msg: "usage: mark [-error-format text|json] <read|generate|names> [flags]"}
+	}
+	switch args[0] {
+	case "read":
+		return runRead(args[1:], stderr)
+	case "generate":
+		return runGenerate(args[1:], stdout, stderr)
+	case "names":
+		return runNames(args[1:], stdout, stderr)
+	case "corpus-stats":
+		return runCorpusStats(args[1:], stdout, stderr)
+	case "stats":
+		return runStats(args[1:], stdout, stderr)
+	case "gen-corpus":
+		// Undocumented: synthesizes benchmark corpora, see gencorpus.go.
+		return runGenCorpus(args[1:], stdout, stderr)
+	case "serve":
+		return runServe(args[1:], stderr)
+	case "remove-word":
+		return runRemoveWord(args[1:], stderr)
+	case "subset":
+		return runSubset(args[1:], stderr)
+	case "analyze":
+		return runAnalyze(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	}
+	return &usageError{msg: fmt.Sprintf("unknown mode %q (want read, generate, names, corpus-stats, stats, serve, remove-word, subset, analyze, or verify)", args[0])}
+}
 
-	key := ""
-	val := make([]string, 0)
+// runRead builds a chain from the input files and writes the frequency
+// table to the -out model file.
+func runRead(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark read", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	prefixLen := fs.Int("prefix", 2, "prefix length in words")
+	out := fs.String("out", "", "model file to write")
+	continuous := fs.Bool("continuous", false, "carry the rolling prefix across input files instead of resetting it")
+	update := fs.Bool("update", false, "load the existing -out model and train the inputs into it, accumulating counts")
+	joinWith := fs.String("join-with", " ", "string placed between generated tokens, recorded in the model")
+	splitOn := fs.String("split-on", "", "regexp the tokenizer splits input on instead of whitespace, recorded in the model")
+	strictOrder := fs.Bool("strict-order", false, "fail the build when the prefix length is too high for the corpus size")
+	format := fs.String("format", "text", `model format to write: "text", "json", "bin" (gob+gzip, fastest to load), "legacy", or "flat" (read-only, memory-mappable)`)
+	failFast := fs.Bool("fail-fast", false, "stop and fail at the first input that cannot be ingested")
+	strict := fs.Bool("strict", false, "fail after ingesting everything if any input failed")
+	bugCompatible := fs.Bool("bug-compatible", false, "with -format legacy, reproduce the original writer's inflated counts")
+	force := fs.Bool("force", false, "ingest inputs even when they look like model files")
+	skip := fs.String("skip", "", `comma-separated gitignore-style patterns pruned inside directory inputs (e.g. 'node_modules/**,*.min.js')`)
+	tokenizerMap := fs.String("tokenizer-map", "", `comma-separated .ext=pipeline entries choosing a cleaning pipeline per file extension (e.g. '.md=markdown,.log=ws')`)
+	collect := fs.String("collect", "", `comma-separated build statistics to collect in the same pass and record in the model (e.g. 'sentence-lengths,terminators')`)
+	jobs := fs.Int("jobs", 1, "files trained in parallel; 0 means one per CPU")
+	sentences := fs.Bool("sentences", false, "record sentence-opening prefixes in the model, for 'generate -sentences'")
+	reverse := fs.Bool("reverse", false, "also record the reverse index in the model, for 'generate -end'")
+	mode := fs.String("mode", "word", `chain level: "word", or "char" for one token per rune, recorded in the model`)
+	lowercase := fs.Bool("lowercase", false, "fold tokens to lower case so case variants share one entry, recorded in the model")
+	splitPunct := fs.Bool("split-punct", false, "split leading/trailing punctuation into separate tokens, recorded in the model")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if err := checkFlagConflicts("read", fs, stderr); err != nil {
+		return err
+	}
+	if *prefixLen < 1 {
+		return &usageError{msg: "-prefix must be at least 1"}
+	}
+	if *out == "" {
+		return &usageError{msg: "read mode requires -out"}
+	}
+	switch *format {
+	case "text", "json", "bin", "legacy", "flat":
+	default:
+		return &usageError{msg: `-format must be "text", "json", "bin", "legacy", or "flat"`}
+	}
+	switch *mode {
+	case "word", "char":
+	default:
+		return &usageError{msg: `-mode must be "word" or "char"`}
+	}
+	if *bugCompatible && *format != "legacy" {
+		return &usageError{msg: "-bug-compatible only applies to -format legacy"}
+	}
+	if *jobs < 0 {
+		return &usageError{msg: "-jobs must not be negative"}
+	}
+	if *jobs == 0 {
+		*jobs = runtime.NumCPU()
+	}
+	tokenizers, err := parseTokenizerMap(*tokenizerMap)
+	if err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	collectors, err := parseCollectors(*collect)
+	if err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		return &usageError{msg: "read mode requires at least one input file"}
+	}
+	// Training on a model file "works" and produces a nonsense
+	// meta-model over count lines, so it has to be asked for explicitly.
+	if !*force {
+		for _, in := range inputs {
+			if kind := sniffFile(in); kind.isModel() {
+				return &usageError{msg: fmt.Sprintf("input %s looks like a %s, not a corpus — training on it would build a meta-model over its count lines (pass -force to ingest it anyway)", in, kind)}
+			}
+		}
+	}
 
-	for i := 0; i < len(splitStringList)-1; i++ {
-		// 前 prefixLen 作为key
-		if i < prefixLen {
-			if key == "\"\"" {
-				key = ""
-				key = key + splitStringList[i] + " "
-			} else {
-				key = key + splitStringList[i] + " "
+	var c *Chain
+	if *update {
+		// The existing model fixes the chain's whole configuration;
+		// conflictMatrix rejects the flags that would contradict it.
+		if isFlatModel(*out) {
+			return &usageError{msg: fmt.Sprintf("%s is a flat model, which cannot be updated — rebuild it from the corpora instead", *out)}
+		}
+		loaded, err := loadModelFile(*out)
+		if err != nil {
+			return err
+		}
+		c = loaded
+	} else {
+		c = NewChain(*prefixLen)
+		c.SetJoiner(*joinWith)
+		if *mode == "char" {
+			c.SetCharLevel(true)
+			// An explicitly chosen joiner beats char mode's default of none.
+			if *joinWith != " " {
+				c.SetJoiner(*joinWith)
 			}
-		} else {
-			if splitStringList[i] == "" {
-				//fmt.Println("碰到为空的值了")
-				continue
+		}
+		if *sentences {
+			c.TrackSentenceStarts()
+		}
+		if *reverse {
+			c.TrackReverse()
+		}
+		c.SetLowercase(*lowercase)
+		c.SetSplitPunct(*splitPunct)
+		if *splitOn != "" {
+			if err := c.SetTokenizer(*splitOn); err != nil {
+				return &usageError{msg: err.Error()}
 			}
-			val = append(val, strings.TrimSpace(splitStringList[i]))
 		}
 	}
+	for _, col := range collectors {
+		c.AddCollector(col)
+	}
+	// Each input is an independent document by default: the prefix
+	// resets between files so no fake transition spans a file boundary.
+	// With -continuous the prefix carries over, for chapter-split works.
+	opts := IngestOptions{
+		Continuous: *continuous,
+		FailFast:   *failFast,
+		Skip:       parseSkipList(*skip).list(),
+		Tokenizers: tokenizers,
+		Jobs:       *jobs,
+	}
+	if buildErrs := c.BuildFromInputs(inputs, opts); buildErrs != nil {
+		// With -fail-fast or -strict (or when every file was lost) the
+		// aggregate is the run's failure and is rendered by reportError;
+		// otherwise it is only a warning table.
+		if *failFast || *strict || len(buildErrs.Errs) == buildErrs.attempted {
+			return buildErrs
+		}
+		buildErrs.renderTable(stderr)
+	}
 
-	//fmt.Println("key============", key)
-	//fmt.Println("Val:", val)
-
-	return strings.TrimSpace(key), val
+	if err := c.checkOrderRatio(inputs, *strictOrder, stderr); err != nil {
+		return err
+	}
+	c.finishCollectors()
+
+	write := c.writeModel
+	switch *format {
+	case "flat":
+		write = c.writeFlatModel
+	case "json":
+		write = c.writeJSONModel
+	case "bin":
+		write = c.Save
+	case "legacy":
+		write = func(w io.Writer) error { return c.writeLegacyModel(w, *bugCompatible) }
+	}
+	if err := atomicWriteFile(*out, write); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "wrote model with %d prefixes to %s (fingerprint %x)\n", len(c.chain), *out, c.Fingerprint())
+	return nil
 }
 
-func main() {
-	// Register command-line flags => pointer. This is the default format
-	//mode := os.Args[1]
-	mode := "generate"
-
-	if mode == "read" {
-		// mode selection
-		prefixLen, _ := strconv.Atoi(os.Args[2])
-		outFileDir := os.Args[3]
-		inFileDir := os.Args[4:]
-
-		rand.Seed(time.Now().UnixNano()) // Seed the random number generator.
-
-		// Initialize a new Chain.
-		c := NewChain(prefixLen)
-
-		outFile, _ := os.Create(outFileDir)
-		defer outFile.Close()
-		fmt.Println("We have successfully read, now the program begins:")
-
-		count := 0
-		for i := 0; i < len(inFileDir); i++ {
+// runGenerate loads the -model frequency table and writes up to -words
+// generated words to stdout.
+func runGenerate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file(s) to read, comma-separated; later files merge into the first")
+	weightsFlag := fs.String("weights", "", "comma-separated per-model count multipliers matching -model (e.g. 3,1), biasing the blend")
+	words := fs.Int("words", 100, "maximum number of words to generate")
+	startPhrase := fs.String("start", "", "phrase to continue from; its last words seed the prefix window")
+	endPhrase := fs.String("end", "", "phrase the output must end with; walks the model's reverse index backwards (needs 'read -reverse')")
+	seed := fs.Int64("seed", 0, "random seed; the same model and seed give byte-identical output, 0 seeds from the clock")
+	backoff := fs.Bool("backoff", false, "at dead ends, retry on shorter prefixes and finally restart instead of stopping")
+	sentences := fs.Bool("sentences", false, "start at a recorded sentence-opening prefix and run on until terminal punctuation")
+	maxTime := fs.Duration("max-time", 0, "hard cap on generation time (e.g. 200ms); 0 means no cap")
+	maxSteps := fs.Int("max-steps", 0, "cap on sampling steps regardless of words produced; 0 derives it from -words")
+	countPunct := fs.Bool("count-punct", false, "make punctuation-only tokens consume the -words budget")
+	strict := fs.Bool("strict", false, "fail if the word accounting contract does not hold on the formatted output")
+	asJSON := fs.Bool("json", false, "emit JSON with the text and run metadata instead of plain text")
+	validateKeys := fs.String("validate-keys", "", `check key arity against the header at load: "sample" or "full"`)
+	tempSchedule := fs.String("temperature-schedule", "", `interpolate sampling temperature linearly across the output, "start:end" (e.g. 0.8:1.5)`)
+	temperature := fs.Float64("temperature", 1, "sampling temperature: below 1 sharpens toward frequent suffixes, above 1 flattens toward uniform")
+	topK := fs.Int("top-k", 0, "restrict each draw to the k most frequent suffixes (boundary ties all stay in); 0 means no cutoff")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if err := checkFlagConflicts("generate", fs, stderr); err != nil {
+		return err
+	}
+	if *model == "" {
+		return &usageError{msg: "generate mode requires -model"}
+	}
+	models := strings.Split(*model, ",")
+	weights, err := parseModelWeights(*weightsFlag, len(models))
+	if err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *words < 0 {
+		return &usageError{msg: "-words must not be negative"}
+	}
+	if *maxSteps < 0 {
+		return &usageError{msg: "-max-steps must not be negative"}
+	}
+	switch *validateKeys {
+	case "", "sample", "full":
+	default:
+		return &usageError{msg: `-validate-keys must be "sample" or "full"`}
+	}
+	if *temperature <= 0 {
+		return &usageError{msg: "-temperature must be positive"}
+	}
+	if *topK < 0 {
+		return &usageError{msg: "-top-k must not be negative"}
+	}
+	var temperatureAt func(step, total int) float64
+	if *tempSchedule != "" {
+		var err error
+		if temperatureAt, err = parseTemperatureSchedule(*tempSchedule); err != nil {
+			return &usageError{msg: err.Error()}
+		}
+	}
 
-			// open the input files
-			fi, err := os.Open(inFileDir[i])
-			if err != nil {
-				panic(err)
+	// A corpus handed to generate would only fail on the missing model
+	// header; catch it up front and point at the right mode instead.
+	for _, m := range models {
+		if kind := sniffFile(m); kind == contentCorpus {
+			return &corruptError{path: m, line: 1, msg: "this looks like a corpus, not a model — did you mean 'mark read'?"}
+		}
+	}
+	if len(models) > 1 {
+		for _, m := range models {
+			if isFlatModel(m) {
+				return &usageError{msg: fmt.Sprintf("%s is a flat model, which cannot be merged — convert it to a text or binary model first", m)}
 			}
-			defer fi.Close()
+		}
+	}
 
-			// Build chains from standard input.
-			c.Build(fi)
+	// Flat models are memory-mapped and searched in place; text models
+	// are parsed into an in-memory chain. The file's magic decides.
+	if len(models) == 1 && isFlatModel(models[0]) {
+		if temperatureAt != nil {
+			return &usageError{msg: "-temperature-schedule is not supported for flat models"}
+		}
+		if *temperature != 1 {
+			return &usageError{msg: "-temperature is not supported for flat models"}
+		}
+		if *topK > 0 {
+			return &usageError{msg: "-top-k is not supported for flat models"}
+		}
+		if *startPhrase != "" {
+			return &usageError{msg: "-start is not supported for flat models"}
+		}
+		if *backoff {
+			return &usageError{msg: "-backoff is not supported for flat models"}
+		}
+		if *sentences {
+			return &usageError{msg: "-sentences is not supported for flat models"}
+		}
+		if *endPhrase != "" {
+			return &usageError{msg: "-end is not supported for flat models"}
+		}
+		if *weightsFlag != "" {
+			return &usageError{msg: "-weights is not supported for flat models"}
+		}
+		m, err := openFlatModel(models[0])
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		seedRand(*seed)
+		start := time.Now()
+		// The flat walk takes exactly one step per word, so the budget
+		// reduces to capping the word target.
+		n, stoppedEarly := *words, ""
+		if *maxSteps > 0 && *maxSteps < n {
+			n = *maxSteps
+		}
+		text, produced := m.generateCounted(n)
+		if n < *words && produced == n {
+			stoppedEarly = "step budget"
+		}
+		return emitGenerated(stdout, *asJSON, text, time.Since(start), false, stoppedEarly)
+	}
 
-			fmt.Println(c)
-			// the first line, specify the number of prefix length
-			if count == 0 {
-				fmt.Fprintln(outFile, prefixLen)
-			}
-			// format: map[string][]string
-			mapChain := c.chain
-			//fmt.Println(mapChain)
-			// key -> string val->[]string
-			for key, val := range mapChain {
-				//fmt.Println(key)
-				fmt.Fprint(outFile, key, " ", ValIteration(val), "\n")
-				//fmt.Print(key, " ", ValIteration(val), "\n")
+	seedRand(*seed)
+	c, err := blendModels(models, weights)
+	if err != nil {
+		return err
+	}
+	if *validateKeys != "" {
+		if err := c.validateKeyArity(*validateKeys == "full"); err != nil {
+			var ce *corruptError
+			if errors.As(err, &ce) {
+				ce.path = *model
 			}
-			count++
-			fmt.Println("==================== one epoch finished =====================================")
+			return err
 		}
+	}
 
-	} else {
-		fmt.Println("Mode generate selected!!!")
-
-		modelFileDir := "poe.model"
-		//modelFileDir := os.Args[2]
-		//numWords := os.Args[3]
-		// 读取frequency table
-		file, err := os.Open(modelFileDir)
+	if *endPhrase != "" {
+		// A backward walk: the phrase anchors the end of the output and
+		// the reverse index supplies what came before it.
+		start := time.Now()
+		text, err := c.GenerateEndingWith(*endPhrase, *words)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		defer file.Close()
+		return emitGenerated(stdout, *asJSON, text, time.Since(start), false, "")
+	}
 
-		// read first line to gain the number of prefix
-		scanner := bufio.NewScanner(file)
-		numList := make([]int, 0)
-		for scanner.Scan() {
-			prefixLenRead, _ := strconv.Atoi(scanner.Text())
-			numList = append(numList, prefixLenRead)
-			break
+	opts := GenerateOptions{Words: *words, MaxDuration: *maxTime, Temperature: *temperature, TemperatureAt: temperatureAt, TopK: *topK, MaxSteps: *maxSteps, CountPunct: *countPunct, Start: *startPhrase, Backoff: *backoff, Sentences: *sentences}
+	// Plain-text runs stream: words hit stdout as they are chosen, so
+	// very long outputs appear progressively. JSON needs the complete
+	// text, -strict checks the formatted output, and -start leads with
+	// the phrase, so those keep buffering.
+	if !*asJSON && !*strict && *startPhrase == "" {
+		bw := bufio.NewWriter(stdout)
+		opts.Sink = bw
+		res := c.GenerateWithOptions(opts)
+		if res.SuspectCorrupt && *words > 0 {
+			return &corruptError{path: *model, msg: "generation produced no words: the start prefix is missing, so the model's keys likely disagree with its prefix length (re-check with -validate-keys full)"}
+		}
+		if res.WriteErr != nil {
+			return &writeError{path: "stdout", err: res.WriteErr}
 		}
+		if err := bw.Flush(); err != nil {
+			return &writeError{path: "stdout", err: err}
+		}
+		fmt.Fprintln(stdout)
+		return nil
+	}
+	res := c.GenerateWithOptions(opts)
+	if res.SuspectCorrupt && *words > 0 {
+		return &corruptError{path: *model, msg: "generation produced no words: the start prefix is missing, so the model's keys likely disagree with its prefix length (re-check with -validate-keys full)"}
+	}
+	if *strict {
+		if err := c.checkWordAccounting(res); err != nil {
+			return &corruptError{path: *model, msg: err.Error()}
+		}
+	}
+	text := res.Text
+	if *startPhrase != "" {
+		// The phrase leads the output, matching GenerateFrom.
+		if text == "" {
+			text = *startPhrase
+		} else {
+			text = *startPhrase + c.joinWith + text
+		}
+	}
+	return emitGenerated(stdout, *asJSON, text, res.Elapsed, res.TimedOut, res.StoppedEarly)
+}
 
-		prefixLen := numList[0]
-		fmt.Println("The first line would be: ", prefixLen)
+// parseModelWeights parses the -weights list: one positive count
+// multiplier per model. An empty spec means unweighted.
+func parseModelWeights(spec string, models int) ([]float64, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) != models {
+		return nil, fmt.Errorf("-weights lists %d values for %d models", len(parts), models)
+	}
+	ws := make([]float64, len(parts))
+	for i, p := range parts {
+		w, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad -weights entry %q: %v", p, err)
+		}
+		if err := validWeight(w); err != nil {
+			return nil, fmt.Errorf("bad -weights entry %q: %v", p, err)
+		}
+		ws[i] = w
+	}
+	return ws, nil
+}
 
-		// Reinitilize a chain
-		c := NewChain(prefixLen)
+// blendModels loads every model file and folds them into one chain,
+// each multiplied by its weight (nil means unweighted). The blend
+// keeps the first model's configuration; a single unweighted model is
+// returned as loaded.
+func blendModels(models []string, weights []float64) (*Chain, error) {
+	first, err := loadModelFile(models[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 1 && weights == nil {
+		return first, nil
+	}
+	weight := func(i int) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
+	merged := first.emptyClone()
+	if err := merged.MergeWeighted(first, weight(0)); err != nil {
+		return nil, err
+	}
+	for i, path := range models[1:] {
+		other, err := loadModelFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := merged.MergeWeighted(other, weight(i+1)); err != nil {
+			return nil, fmt.Errorf("merging %s into %s: %w", path, models[0], err)
+		}
+	}
+	return merged, nil
+}
 
-		c.BuildFromRead(scanner, prefixLen)
-		//fmt.Println(c)
+// loadTextChain opens a text model file, reads the prefix length
+// header, and parses the rest into an in-memory chain.
+func loadTextChain(path string) (*Chain, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &notFoundError{what: "model", path: path, err: err}
+	}
+	defer file.Close()
 
-		text := c.Generate(100) // Generate text.
-		fmt.Println(text)       // Write text to standard output.
+	// The first line holds the prefix length the model was built with.
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxScanLine)
+	if !scanner.Scan() {
+		return nil, &corruptError{path: path, line: 1, msg: "missing prefix length header"}
+	}
+	prefixLen, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil || prefixLen < 1 {
+		return nil, &corruptError{path: path, line: 1, msg: "first line must be a positive prefix length"}
+	}
+	c := NewChain(prefixLen)
+	// Both formats start with a bare prefix length, so the first data
+	// line decides: v2 lines are tab-separated, legacy lines are not.
+	var first string
+	hasFirst := false
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			first, hasFirst = line, true
+			break
+		}
+	}
+	if hasFirst && !strings.Contains(first, "\t") {
+		err = c.readLegacyModelLines(scanner, first)
+	} else {
+		if hasFirst {
+			err = c.applyModelLine(first, 2)
+		}
+		if err == nil {
+			err = c.readModelLinesAt(scanner, 2)
+		}
+	}
+	if err != nil {
+		var ce *corruptError
+		if errors.As(err, &ce) {
+			ce.path = path
+			return nil, err
+		}
+		return nil, &corruptError{path: path, msg: err.Error()}
+	}
+	if len(c.chain) == 0 {
+		return nil, fmt.Errorf("model %s: %w", path, ErrEmptyChain)
+	}
+	return c, nil
+}
 
+// emitGenerated writes generated text to stdout, either as plain text
+// or as one JSON object with the run metadata.
+func emitGenerated(stdout io.Writer, asJSON bool, text string, elapsed time.Duration, timedOut bool, stoppedEarly string) error {
+	if asJSON {
+		meta := struct {
+			Text         string `json:"text"`
+			Words        int    `json:"words"`
+			Elapsed      string `json:"elapsed"`
+			TimedOut     bool   `json:"timedOut"`
+			StoppedEarly string `json:"stoppedEarly,omitempty"`
+		}{text, len(strings.Fields(text)), elapsed.String(), timedOut, stoppedEarly}
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return &writeError{path: "stdout", err: err}
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
 	}
+	fmt.Fprintln(stdout, text)
+	return nil
 }
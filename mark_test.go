@@ -0,0 +1,218 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI drives Main in-process and returns the exit code plus the
+// captured stdout and stderr.
+func runCLI(args ...string) (code int, stdout, stderr string) {
+	var out, errBuf bytes.Buffer
+	code = Main(args, &out, &errBuf)
+	return code, out.String(), errBuf.String()
+}
+
+// decodeErrorJSON unmarshals the single JSON error object emitted with
+// -error-format json.
+func decodeErrorJSON(t *testing.T, stderr string) errorJSON {
+	t.Helper()
+	var obj errorJSON
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stderr)), &obj); err != nil {
+		t.Fatalf("stderr is not a single JSON object: %v\nstderr: %q", err, stderr)
+	}
+	return obj
+}
+
+func TestErrorFormatJSONUsage(t *testing.T) {
+	code, _, stderr := runCLI("-error-format", "json", "frobnicate")
+	if code != exitUsage {
+		t.Fatalf("exit code = %d, want %d", code, exitUsage)
+	}
+	obj := decodeErrorJSON(t, stderr)
+	if obj.Code != exitUsage {
+		t.Errorf("code field = %d, want %d", obj.Code, exitUsage)
+	}
+	if !strings.Contains(obj.Error, "frobnicate") {
+		t.Errorf("error field %q does not name the bad mode", obj.Error)
+	}
+}
+
+func TestErrorFormatJSONModelNotFound(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "no-such.model")
+	code, _, stderr := runCLI("-error-format", "json", "generate", "-model", missing)
+	if code != exitNotFound {
+		t.Fatalf("exit code = %d, want %d", code, exitNotFound)
+	}
+	obj := decodeErrorJSON(t, stderr)
+	if obj.Code != exitNotFound {
+		t.Errorf("code field = %d, want %d", obj.Code, exitNotFound)
+	}
+	if obj.Path != missing {
+		t.Errorf("path field = %q, want %q", obj.Path, missing)
+	}
+}
+
+func TestErrorFormatJSONCorruptModel(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.model")
+	if err := os.WriteFile(bad, []byte("not a number\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr := runCLI("-error-format", "json", "generate", "-model", bad)
+	if code != exitCorrupt {
+		t.Fatalf("exit code = %d, want %d", code, exitCorrupt)
+	}
+	obj := decodeErrorJSON(t, stderr)
+	if obj.Code != exitCorrupt {
+		t.Errorf("code field = %d, want %d", obj.Code, exitCorrupt)
+	}
+	if obj.Path != bad || obj.Line != 1 {
+		t.Errorf("path/line = %q/%d, want %q/1", obj.Path, obj.Line, bad)
+	}
+}
+
+func TestErrorFormatJSONInputNotFound(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "no-such.txt")
+	code, _, stderr := runCLI("-error-format", "json", "read",
+		"-out", filepath.Join(dir, "out.model"), missing)
+	if code != exitNotFound {
+		t.Fatalf("exit code = %d, want %d", code, exitNotFound)
+	}
+	obj := decodeErrorJSON(t, stderr)
+	if obj.Path != missing {
+		t.Errorf("path field = %q, want %q", obj.Path, missing)
+	}
+}
+
+func TestContinuousBuildAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	ch1 := filepath.Join(dir, "ch1.txt")
+	ch2 := filepath.Join(dir, "ch2.txt")
+	if err := os.WriteFile(ch1, []byte("alpha beta\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ch2, []byte("gamma delta\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// With -continuous the last word of ch1 connects to the first word
+	// of ch2, so the model must contain the boundary bigram beta->gamma.
+	buildModel := func(continuous bool) string {
+		t.Helper()
+		out := filepath.Join(t.TempDir(), "out.model")
+		args := []string{"read", "-prefix", "1", "-out", out}
+		if continuous {
+			args = append(args, "-continuous")
+		}
+		args = append(args, ch1, ch2)
+		if code, _, stderr := runCLI(args...); code != 0 {
+			t.Fatalf("read exited %d: %s", code, stderr)
+		}
+		model, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(model)
+	}
+
+	boundary := "beta\t\"gamma\""
+	if model := buildModel(true); !strings.Contains(model, boundary) {
+		t.Errorf("-continuous model is missing the boundary bigram beta->gamma:\n%s", model)
+	}
+	if model := buildModel(false); strings.Contains(model, boundary) {
+		t.Errorf("default model contains the boundary bigram beta->gamma:\n%s", model)
+	}
+}
+
+func TestErrorFormatDefaultIsText(t *testing.T) {
+	code, _, stderr := runCLI("generate", "-model", "no-such.model")
+	if code != exitNotFound {
+		t.Fatalf("exit code = %d, want %d", code, exitNotFound)
+	}
+	if !strings.HasPrefix(stderr, "mark: ") {
+		t.Errorf("default stderr %q is not human-readable text", stderr)
+	}
+	if strings.Contains(stderr, "{") {
+		t.Errorf("default stderr %q looks like JSON", stderr)
+	}
+}
+
+func TestModelWritesEachKeyOnce(t *testing.T) {
+	// The chain is serialized once after every input has been read, so
+	// a key trained by an early file must not reappear when later files
+	// are processed.
+	dir := t.TempDir()
+	ch1 := filepath.Join(dir, "ch1.txt")
+	ch2 := filepath.Join(dir, "ch2.txt")
+	if err := os.WriteFile(ch1, []byte("alpha beta alpha\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ch2, []byte("alpha gamma\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", out, ch1, ch2); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	model, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(model), "\n")[1:] {
+		if line == "" || !strings.Contains(line, "\t") {
+			continue
+		}
+		key := line[:strings.Index(line, "\t")]
+		if seen[key] {
+			t.Errorf("key %q serialized more than once:\n%s", key, model)
+		}
+		seen[key] = true
+	}
+	// Both files still land in the one model: "alpha" was seen in each,
+	// so its line must carry both continuations.
+	if !seen["alpha"] {
+		t.Fatalf("key alpha missing from the model:\n%s", model)
+	}
+	for _, want := range []string{`"beta"`, `"gamma"`} {
+		if !strings.Contains(string(model), want) {
+			t.Errorf("model is missing suffix %s:\n%s", want, model)
+		}
+	}
+}
+
+// TestArgumentValidation sweeps the argument-checking paths of the two
+// core modes in one table: every row must fail as a usage error before
+// any file is touched, naming the offending flag or requirement.
+func TestArgumentValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string // fragment the stderr message must carry
+	}{
+		{"no mode", nil, "usage: mark"},
+		{"unknown mode", []string{"frobnicate"}, "unknown mode"},
+		{"read without out", []string{"read", "in.txt"}, "-out"},
+		{"read without inputs", []string{"read", "-out", "m.model"}, "input"},
+		{"read prefix zero", []string{"read", "-prefix", "0", "-out", "m.model", "in.txt"}, "-prefix"},
+		{"read bad format", []string{"read", "-format", "bogus", "-out", "m.model", "in.txt"}, "-format"},
+		{"read bad mode", []string{"read", "-mode", "byte", "-out", "m.model", "in.txt"}, "-mode"},
+		{"generate without model", []string{"generate"}, "-model"},
+		{"generate negative words", []string{"generate", "-model", "m.model", "-words", "-1"}, "-words"},
+		{"generate bad validate-keys", []string{"generate", "-model", "m.model", "-validate-keys", "bogus"}, "-validate-keys"},
+	}
+	for _, tc := range cases {
+		code, _, stderr := runCLI(tc.args...)
+		if code != exitUsage {
+			t.Errorf("%s: exited %d, want %d (stderr %q)", tc.name, code, exitUsage, stderr)
+		}
+		if !strings.Contains(stderr, tc.want) {
+			t.Errorf("%s: stderr %q does not mention %q", tc.name, stderr, tc.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+)
+
+// repeatedCorpus is deliberately repetitive: common bigrams recur
+// thousands of times, which is exactly the shape that made the old
+// representation blow up on large inputs.
+func repeatedCorpus() string {
+	return strings.Repeat("one of the many and one of the few of the lot ", 2000)
+}
+
+// The chain once stored suffixes as map[string][]string with one slice
+// element per occurrence, so a bigram seen ten thousand times held ten
+// thousand duplicate strings. The benchmarks keep that shape alive
+// below to show what the count map saves on repetitive text; compare
+// the B/op columns.
+func BenchmarkBuildCountsMap(b *testing.B) {
+	corpus := repeatedCorpus()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := NewChain(2)
+		c.Build(strings.NewReader(corpus))
+	}
+}
+
+func BenchmarkBuildExpandedSlices(b *testing.B) {
+	corpus := repeatedCorpus()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		chain := make(map[string][]string)
+		p := make(Prefix, 2)
+		for _, s := range strings.Fields(corpus) {
+			key := p.String()
+			chain[key] = append(chain[key], s)
+			p.Shift(s)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package markov
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// chainFrom is a tiny builder for merge tests: a prefix-1 chain
+// trained on one line of text.
+func chainFrom(t *testing.T, text string) *Chain {
+	t.Helper()
+	c := NewChain(1)
+	if err := c.Build(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMergeOverlappingPrefixes(t *testing.T) {
+	a := chainFrom(t, "sun rises sun sets")
+	b := chainFrom(t, "sun rises again")
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.chain["sun"]["rises"]; got != 2 {
+		t.Errorf(`chain["sun"]["rises"] = %v after merge, want 2`, got)
+	}
+	if got := a.chain["rises"]["again"]; got != 1 {
+		t.Errorf(`chain["rises"]["again"] = %v after merge, want 1`, got)
+	}
+}
+
+func TestMergeDisjointPrefixes(t *testing.T) {
+	a := chainFrom(t, "one two")
+	b := chainFrom(t, "three four")
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"one", "three"} {
+		if _, ok := a.chain[key]; !ok {
+			t.Errorf("merged chain is missing prefix %q", key)
+		}
+	}
+}
+
+func TestMergeMismatchedPrefixLengths(t *testing.T) {
+	a := chainFrom(t, "one two three")
+	b := NewChain(2)
+	if err := b.Build(strings.NewReader("one two three")); err != nil {
+		t.Fatal(err)
+	}
+	err := a.Merge(b)
+	if !errors.Is(err, ErrIncompatibleModels) {
+		t.Fatalf("merging prefix lengths 1 and 2 returned %v, want ErrIncompatibleModels", err)
+	}
+	if got := a.chain["one"]["two"]; got != 1 {
+		t.Errorf("failed merge changed the receiver: count %v, want 1", got)
+	}
+}
+
+func TestMergeMismatchedLevels(t *testing.T) {
+	a := chainFrom(t, "ab ab")
+	b := NewChain(1)
+	b.SetCharLevel(true)
+	if err := b.Build(strings.NewReader("abab")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Merge(b); !errors.Is(err, ErrIncompatibleModels) {
+		t.Errorf("merging word and char levels returned %v, want ErrIncompatibleModels", err)
+	}
+}
+
+func TestMergeWeighted(t *testing.T) {
+	a := chainFrom(t, "sun rises")
+	b := chainFrom(t, "sun rises")
+	if err := a.MergeWeighted(b, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.chain["sun"]["rises"]; got != 4 {
+		t.Errorf(`chain["sun"]["rises"] = %v after weight-3 merge, want 4`, got)
+	}
+	if err := a.MergeWeighted(b, 0); err == nil {
+		t.Error("MergeWeighted accepted weight 0")
+	}
+}
+
+// buildModelFile trains a model on text and writes it to dir for the
+// CLI blend tests.
+func buildModelFile(t *testing.T, dir, name, text string, prefix int) string {
+	t.Helper()
+	corpus := filepath.Join(dir, name+".txt")
+	if err := os.WriteFile(corpus, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, name+".model")
+	if code, _, stderr := runCLI("read", "-prefix", strconv.Itoa(prefix), "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	return model
+}
+
+func TestGenerateBlendsModels(t *testing.T) {
+	dir := t.TempDir()
+	m1 := buildModelFile(t, dir, "m1", strings.Repeat("tick tock ", 10), 1)
+	m2 := buildModelFile(t, dir, "m2", strings.Repeat("ding dong ", 10), 1)
+	code, stdout, stderr := runCLI("generate", "-model", m1+","+m2, "-seed", "1", "-words", "20")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output from the blended models")
+	}
+}
+
+func TestGenerateBlendRejectsMismatchedModels(t *testing.T) {
+	dir := t.TempDir()
+	m1 := buildModelFile(t, dir, "m1", "one two three one two", 1)
+	m2 := buildModelFile(t, dir, "m2", "one two three one two", 2)
+	code, _, stderr := runCLI("generate", "-model", m1+","+m2, "-words", "5")
+	if code != exitIncompatible {
+		t.Errorf("blending mismatched prefix lengths exited %d (%s), want %d", code, stderr, exitIncompatible)
+	}
+	if !strings.Contains(stderr, "prefix length") {
+		t.Errorf("stderr %q does not name the prefix lengths", stderr)
+	}
+}
+
+func TestGenerateWeightsValidation(t *testing.T) {
+	dir := t.TempDir()
+	m1 := buildModelFile(t, dir, "m1", "a b a c a b", 1)
+	m2 := buildModelFile(t, dir, "m2", "a b a d a b", 1)
+	for _, tc := range []struct {
+		name    string
+		weights string
+	}{
+		{"wrong count", "3"},
+		{"zero weight", "3,0"},
+		{"not a number", "3,x"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, stderr := runCLI("generate", "-model", m1+","+m2, "-weights", tc.weights, "-words", "5")
+			if code != exitUsage || !strings.Contains(stderr, "-weights") {
+				t.Errorf("-weights %q exited %d (%s), want a usage error naming -weights", tc.weights, code, stderr)
+			}
+		})
+	}
+	if code, _, stderr := runCLI("generate", "-model", m1+","+m2, "-weights", "3,1", "-seed", "1", "-words", "10"); code != 0 {
+		t.Errorf("valid -weights exited %d: %s", code, stderr)
+	}
+}
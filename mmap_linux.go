@@ -0,0 +1,30 @@
+//go:build linux
+
+package markov
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the file read-only. The mapping is shared, so the OS
+// page cache backs the model and multiple server processes reading the
+// same file share the memory. The returned func unmaps it.
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if st.Size() == 0 {
+		return nil, nil, fmt.Errorf("empty file")
+	}
+	if st.Size() != int64(int(st.Size())) {
+		return nil, nil, fmt.Errorf("file too large to map")
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(st.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mmap: %v", err)
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
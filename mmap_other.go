@@ -0,0 +1,19 @@
+//go:build !linux
+
+package markov
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile is the portable fallback: it reads the whole file into
+// memory. Startup is slower than a real mapping but the flat model
+// code above it behaves identically.
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
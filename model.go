@@ -0,0 +1,327 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The model file format, replacing the old space-separated layout that
+// ValIteration produced (which could not represent tokens containing
+// digits, spaces-adjacent counts, or empty strings):
+//
+//	line 1: the prefix length
+//	then zero or more option lines: #name<TAB>quoted-value
+//	then one line per prefix:
+//	prefix<TAB>suffix<TAB>count<TAB>suffix<TAB>count...
+//
+// Suffixes and option values are strconv.Quoted so any token — empty,
+// numeric, containing the field separator or a newline — survives the
+// round trip. A prefix key a raw line cannot carry (a tab inside it, a
+// leading #) is written as a #prefix option with the key quoted; see
+// writeModel. Option lines record build-time settings (tokenizer,
+// joiner) so generate mode picks them up without extra flags; unknown
+// options are skipped for forward compatibility.
+
+// encodeSuffixes serializes a suffix multiset (suffix -> weight) as one
+// tab-separated fragment. Suffixes are emitted in sorted order so the
+// output is stable, and quoted so adversarial tokens are unambiguous.
+// Integral weights are written as plain integers so models that never
+// saw fractional weights are byte-identical to before.
+func encodeSuffixes(counts map[string]float64) string {
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(strconv.Quote(w))
+		b.WriteByte('\t')
+		b.WriteString(formatWeight(counts[w]))
+	}
+	return b.String()
+}
+
+// formatWeight renders a weight for the model file: integers stay
+// integers, fractional weights use the shortest exact float form.
+func formatWeight(w float64) string {
+	if w == math.Trunc(w) && math.Abs(w) < 1<<53 {
+		return strconv.FormatInt(int64(w), 10)
+	}
+	return strconv.FormatFloat(w, 'g', -1, 64)
+}
+
+// decodeSuffixes parses a fragment produced by encodeSuffixes back into
+// a suffix multiset. Counts stay counts: a suffix seen millions of
+// times is one map entry, never an expanded slice. Weights may be
+// integers or floats ("brown 2.5" from externally adjusted models);
+// both land in the same float64 representation.
+func decodeSuffixes(s string) (map[string]float64, error) {
+	counts := make(map[string]float64)
+	if s == "" {
+		return counts, nil
+	}
+	fields := strings.Split(s, "\t")
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("odd number of suffix fields (%d)", len(fields))
+	}
+	for i := 0; i < len(fields); i += 2 {
+		word, err := strconv.Unquote(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("bad suffix %s: %v", fields[i], err)
+		}
+		n, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || n <= 0 {
+			return nil, fmt.Errorf("bad count %q for suffix %q", fields[i+1], word)
+		}
+		counts[word] += n
+	}
+	return counts, nil
+}
+
+// writeModel writes the chain to w in the model file format.
+func (c *Chain) writeModel(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, c.prefixLen); err != nil {
+		return err
+	}
+	if c.joinWith != " " {
+		if _, err := fmt.Fprintf(w, "#join-with\t%s\n", strconv.Quote(c.joinWith)); err != nil {
+			return err
+		}
+	}
+	if c.splitOn != "" {
+		if _, err := fmt.Fprintf(w, "#split-on\t%s\n", strconv.Quote(c.splitOn)); err != nil {
+			return err
+		}
+	}
+	if c.charLevel {
+		if _, err := fmt.Fprintf(w, "#mode\t%s\n", strconv.Quote("char")); err != nil {
+			return err
+		}
+	}
+	if c.lowercase {
+		if _, err := fmt.Fprintf(w, "#lowercase\t%s\n", strconv.Quote("true")); err != nil {
+			return err
+		}
+	}
+	if c.splitPunct {
+		if _, err := fmt.Fprintf(w, "#split-punct\t%s\n", strconv.Quote("true")); err != nil {
+			return err
+		}
+	}
+	if c.reverse != nil {
+		if _, err := fmt.Fprintf(w, "#reverse\t%s\n", strconv.Quote("true")); err != nil {
+			return err
+		}
+	}
+	statNames := make([]string, 0, len(c.stats))
+	for name := range c.stats {
+		statNames = append(statNames, name)
+	}
+	sort.Strings(statNames)
+	for _, name := range statNames {
+		if _, err := fmt.Fprintf(w, "#stats-%s\t%s\n", name, strconv.Quote(c.stats[name])); err != nil {
+			return err
+		}
+	}
+	// One option line per sentence-opening prefix. The key and its count
+	// ride in a single quoted value, tab-separated inside the quotes, so
+	// the one-value option grammar (and readers that predate the option)
+	// are undisturbed.
+	for _, key := range c.sentenceStartKeys() {
+		if _, err := fmt.Fprintf(w, "#sentence-start\t%s\n", strconv.Quote(key+"\t"+formatWeight(c.starts[key]))); err != nil {
+			return err
+		}
+	}
+	keys := make([]string, 0, len(c.chain))
+	for key := range c.chain {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		line := key + "\t" + encodeSuffixes(c.chain[key])
+		// A key the raw line grammar cannot carry — a tab would be read
+		// as the field separator, a leading # as an option line — rides
+		// in a #prefix option instead, with the key inner-quoted so the
+		// tab before the suffix fields stays unambiguous. Ordinary keys
+		// keep the raw layout, byte-identical to older writers.
+		if strings.ContainsRune(key, '\t') || strings.HasPrefix(key, "#") {
+			line = "#prefix\t" + strconv.Quote(strconv.Quote(key)+"\t"+encodeSuffixes(c.chain[key]))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteModel writes the chain to w in the canonical model format, and
+// ReadModel parses one back. They are the exported faces of the text
+// format for programs importing the package; the CLI's read and
+// generate modes go through the same writer and parser.
+func (c *Chain) WriteModel(w io.Writer) error { return c.writeModel(w) }
+
+// ReadModel parses a canonical model, as written by WriteModel, into a
+// new chain.
+func ReadModel(r io.Reader) (*Chain, error) { return readModelStream(r) }
+
+// readModelStream parses a canonical (v2) model from a stream, for
+// callers that are not going through a file on disk.
+func readModelStream(r io.Reader) (*Chain, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScanLine)
+	if !scanner.Scan() {
+		return nil, &corruptError{line: 1, msg: "missing prefix length header"}
+	}
+	prefixLen, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil || prefixLen < 1 {
+		return nil, &corruptError{line: 1, msg: "first line must be a positive prefix length"}
+	}
+	c := NewChain(prefixLen)
+	if err := c.readModelLines(scanner); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readModelLines parses model lines (everything after the prefix length
+// header) from the scanner into the chain. Counts for a prefix seen on
+// several lines accumulate rather than clobbering each other.
+func (c *Chain) readModelLines(scanner *bufio.Scanner) error {
+	return c.readModelLinesAt(scanner, 1) // the header was line 1
+}
+
+// readModelLinesAt is readModelLines starting its line numbering after
+// lastLine, for callers that already consumed data lines.
+func (c *Chain) readModelLinesAt(scanner *bufio.Scanner, lastLine int) error {
+	lineNum := lastLine
+	for scanner.Scan() {
+		lineNum++
+		if err := c.applyModelLine(scanner.Text(), lineNum); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// applyModelLine parses one v2 data line (option or prefix line) into
+// the chain.
+func (c *Chain) applyModelLine(line string, lineNum int) error {
+	if line == "" {
+		return nil
+	}
+	if strings.HasPrefix(line, "#") {
+		if err := c.applyModelOption(line); err != nil {
+			return &corruptError{line: lineNum, msg: err.Error()}
+		}
+		return nil
+	}
+	key, fragment, found := strings.Cut(line, "\t")
+	if !found {
+		return &corruptError{line: lineNum, msg: "no tab-separated suffix fields"}
+	}
+	counts, err := decodeSuffixes(fragment)
+	if err != nil {
+		return &corruptError{line: lineNum, msg: err.Error()}
+	}
+	for word, n := range counts {
+		c.addSuffix(key, word, n)
+	}
+	return nil
+}
+
+// applyModelOption parses one #name<TAB>quoted-value header line and
+// applies it to the chain. Unknown option names are skipped so newer
+// writers stay readable by older binaries.
+func (c *Chain) applyModelOption(line string) error {
+	name, quoted, found := strings.Cut(strings.TrimPrefix(line, "#"), "\t")
+	if !found {
+		return fmt.Errorf("option line %q has no tab-separated value", line)
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return fmt.Errorf("option %s has a bad quoted value %s: %v", name, quoted, err)
+	}
+	switch {
+	case name == "join-with":
+		c.joinWith = value
+	case name == "split-on":
+		if err := c.SetTokenizer(value); err != nil {
+			return err
+		}
+	case name == "mode":
+		// Direct assignment, not SetCharLevel: the joiner is its own
+		// header line and must not be clobbered by option order.
+		switch value {
+		case "char":
+			c.charLevel = true
+		case "word":
+		default:
+			return fmt.Errorf("unknown model mode %q (want word or char)", value)
+		}
+	case name == "lowercase", name == "split-punct":
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("option %s has a bad boolean value %q", name, value)
+		}
+		if name == "lowercase" {
+			c.lowercase = on
+		} else {
+			c.splitPunct = on
+		}
+	case name == "reverse":
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("option %s has a bad boolean value %q", name, value)
+		}
+		if on {
+			// Set before any prefix line is parsed, so the loader's
+			// addSuffix calls rebuild the index as they go.
+			c.TrackReverse()
+		}
+	case name == "prefix":
+		// The escaped form of a data line, for keys the raw grammar
+		// cannot carry; see writeModel.
+		quotedKey, fragment, found := strings.Cut(value, "\t")
+		if !found {
+			return fmt.Errorf("prefix option %q has no suffix fields", value)
+		}
+		key, err := strconv.Unquote(quotedKey)
+		if err != nil {
+			return fmt.Errorf("prefix option has a bad quoted key %s: %v", quotedKey, err)
+		}
+		counts, err := decodeSuffixes(fragment)
+		if err != nil {
+			return err
+		}
+		for word, n := range counts {
+			c.addSuffix(key, word, n)
+		}
+	case name == "sentence-start":
+		// The count follows the last tab: keys from exotic tokenizers
+		// may themselves contain tabs, counts never do.
+		key, count := value, ""
+		if i := strings.LastIndex(value, "\t"); i >= 0 {
+			key, count = value[:i], value[i+1:]
+		}
+		n := 1.0
+		if count != "" {
+			var err error
+			if n, err = strconv.ParseFloat(count, 64); err != nil || n <= 0 {
+				return fmt.Errorf("bad sentence-start count %q for key %q", count, key)
+			}
+		}
+		c.addSentenceStart(key, n)
+	case strings.HasPrefix(name, "stats-"):
+		c.setStat(strings.TrimPrefix(name, "stats-"), value)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package markov
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// nameEnd is the end-of-name sentinel suffix. Rune tokens from the
+// corpus are single printable runes, so a NUL byte cannot collide.
+const nameEnd = "\x00"
+
+// buildNameChain builds a rune-level chain from one name per line, with
+// the usual empty-prefix start filler and an end sentinel after the
+// last rune, so generation knows where names begin and end. It returns
+// the chain and the set of training names (lowercased) for copy checks.
+func buildNameChain(r io.Reader, order int) (*Chain, map[string]bool, error) {
+	c := NewChain(order)
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		p := make(Prefix, order)
+		for i := range p {
+			p[i] = "\"\""
+		}
+		for _, r := range name {
+			c.addSuffix(p.String(), string(r), 1)
+			p.Shift(string(r))
+		}
+		c.addSuffix(p.String(), nameEnd, 1)
+	}
+	return c, seen, scanner.Err()
+}
+
+// generateName walks the rune chain from a fresh prefix until the end
+// sentinel fires or the name exceeds maxLen runes (in which case the
+// candidate is abandoned by the caller's length filter anyway).
+func generateName(c *Chain, maxLen int) string {
+	p := make(Prefix, c.prefixLen)
+	for i := range p {
+		p[i] = "\"\""
+	}
+	var b strings.Builder
+	for n := 0; n <= maxLen; n++ {
+		choices := c.chain[p.String()]
+		if len(choices) == 0 {
+			break
+		}
+		next := weightedChoice(c.rng, choices)
+		if next == nameEnd {
+			break
+		}
+		b.WriteString(next)
+		p.Shift(next)
+	}
+	return b.String()
+}
+
+// titleCase upper-cases the first rune of each word and lower-cases the
+// rest, which is what users expect names to look like.
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		runes := []rune(strings.ToLower(w))
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// runNames implements the name generator preset: a rune-level chain
+// over one name per corpus line, filtered by length, with exact copies
+// of training names rejected and the survivors title-cased.
+func runNames(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark names", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	corpus := fs.String("corpus", "", "file with one training name per line")
+	count := fs.Int("count", 20, "number of names to generate")
+	minLen := fs.Int("min-len", 4, "minimum name length in runes")
+	maxLen := fs.Int("max-len", 10, "maximum name length in runes")
+	order := fs.Int("order", 3, "rune prefix length of the chain")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *corpus == "" {
+		return &usageError{msg: "names mode requires -corpus"}
+	}
+	if *order < 1 {
+		return &usageError{msg: "-order must be at least 1"}
+	}
+	if *minLen < 1 || *maxLen < *minLen {
+		return &usageError{msg: "-min-len and -max-len must satisfy 1 <= min <= max"}
+	}
+
+	fi, err := os.Open(*corpus)
+	if err != nil {
+		return &notFoundError{what: "input", path: *corpus, err: err}
+	}
+	c, seen, err := buildNameChain(fi, *order)
+	fi.Close()
+	if err != nil {
+		return &notFoundError{what: "input", path: *corpus, err: err}
+	}
+
+	// Candidates that are too short, too long, or exact copies of a
+	// training name are rejected; the attempt budget keeps a tiny or
+	// copy-prone corpus from looping forever.
+	emitted := 0
+	for attempts := 0; emitted < *count && attempts < *count*200; attempts++ {
+		name := generateName(c, *maxLen)
+		runes := len([]rune(name))
+		if runes < *minLen || runes > *maxLen {
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		fmt.Fprintln(stdout, titleCase(name))
+		emitted++
+	}
+	if emitted == 0 {
+		return fmt.Errorf("no generated name satisfied the length and copy filters: %w", ErrConstraintsUnsatisfied)
+	}
+	if emitted < *count {
+		fmt.Fprintf(stderr, "only %d of %d names survived the filters; try a larger corpus or a lower -order\n", emitted, *count)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// A corpus small enough that a high-order chain can essentially only
+// reproduce the training names, so the copy filter must provably fire.
+var nameCorpus = []string{
+	"marisa", "marina", "martina", "melina", "melisa", "karina",
+}
+
+func writeNameCorpus(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "names.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(nameCorpus, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNamesRejectsTrainingCopies(t *testing.T) {
+	corpus := writeNameCorpus(t)
+	code, stdout, stderr := runCLI("names", "-corpus", corpus,
+		"-count", "50", "-min-len", "4", "-max-len", "10", "-order", "2")
+	if code != 0 {
+		t.Fatalf("names exited %d: %s", code, stderr)
+	}
+	training := make(map[string]bool)
+	for _, n := range nameCorpus {
+		training[n] = true
+	}
+	for _, line := range strings.Fields(stdout) {
+		name := strings.ToLower(line)
+		if training[name] {
+			t.Errorf("generated name %q is an exact copy of a training name", line)
+		}
+		if n := len([]rune(name)); n < 4 || n > 10 {
+			t.Errorf("generated name %q has length %d, want 4..10", line, n)
+		}
+		runes := []rune(line)
+		if !unicode.IsUpper(runes[0]) {
+			t.Errorf("generated name %q is not title-cased", line)
+		}
+	}
+}
+
+func TestNamesUsageErrors(t *testing.T) {
+	if code, _, _ := runCLI("names"); code != exitUsage {
+		t.Errorf("names without -corpus exited %d, want %d", code, exitUsage)
+	}
+	corpus := writeNameCorpus(t)
+	if code, _, _ := runCLI("names", "-corpus", corpus, "-min-len", "8", "-max-len", "4"); code != exitUsage {
+		t.Errorf("names with min>max exited %d, want %d", code, exitUsage)
+	}
+}
+
+func TestGenerateNameEndsAtSentinel(t *testing.T) {
+	c := NewChain(1)
+	p := Prefix{"\"\""}
+	for _, r := range "abc" {
+		c.addSuffix(p.String(), string(r), 1)
+		p.Shift(string(r))
+	}
+	c.addSuffix(p.String(), nameEnd, 1)
+	for i := 0; i < 20; i++ {
+		if got := generateName(c, 10); got != "abc" {
+			t.Fatalf("generateName = %q, want %q", got, "abc")
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package markov
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// orderRatioThreshold is the distinct-prefix to token ratio above which
+// a model is considered degenerate: nearly every prefix was seen once,
+// so generation can only regurgitate the corpus.
+const orderRatioThreshold = 0.95
+
+// orderSampleTokens caps how many tokens the order estimator re-reads
+// from the corpus; a prefix handful of the input predicts the ratio
+// well enough to suggest a usable order.
+const orderSampleTokens = 50000
+
+// prefixTokenRatio returns the ratio of distinct prefixes to total
+// trained tokens. Values near 1 mean the order is too high for the
+// corpus size.
+func (c *Chain) prefixTokenRatio() float64 {
+	total := 0.0
+	for _, counts := range c.chain {
+		for _, n := range counts {
+			total += n
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(len(c.chain)) / total
+}
+
+// estimateRatioForOrder computes the distinct-prefix ratio a chain of
+// the given order would have over the token sample, walking the same
+// rolling window Build uses (including the empty-string start filler).
+func estimateRatioForOrder(tokens []string, order int) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+	p := make(Prefix, order)
+	for i := range p {
+		p[i] = "\"\""
+	}
+	distinct := make(map[string]struct{}, len(tokens))
+	for _, tok := range tokens {
+		distinct[p.String()] = struct{}{}
+		p.Shift(tok)
+	}
+	return float64(len(distinct)) / float64(len(tokens))
+}
+
+// suggestOrder returns the largest order strictly below the given one
+// whose estimated ratio over the sample falls below the threshold, or
+// 0 when even order 1 is too sparse.
+func suggestOrder(tokens []string, below int, threshold float64) int {
+	for order := below - 1; order >= 1; order-- {
+		if estimateRatioForOrder(tokens, order) < threshold {
+			return order
+		}
+	}
+	return 0
+}
+
+// sampleTokens re-reads up to orderSampleTokens tokens from the inputs
+// through the chain's tokenizer, for the order estimator.
+func (c *Chain) sampleTokens(inputs []string) []string {
+	var tokens []string
+	for _, name := range inputs {
+		if len(tokens) >= orderSampleTokens {
+			break
+		}
+		fi, err := os.Open(name)
+		if err != nil {
+			continue // the build already read it once; a race here only shrinks the sample
+		}
+		c.eachToken(io.LimitReader(fi, 1<<20), func(tok string) {
+			if len(tokens) < orderSampleTokens {
+				tokens = append(tokens, tok)
+			}
+		})
+		fi.Close()
+	}
+	return tokens
+}
+
+// checkOrderRatio warns (or fails, with strict) when the built chain's
+// prefix/token ratio exceeds the threshold, suggesting the largest
+// usable order estimated from a corpus sample.
+func (c *Chain) checkOrderRatio(inputs []string, strict bool, stderr io.Writer) error {
+	ratio := c.prefixTokenRatio()
+	if ratio <= orderRatioThreshold {
+		return nil
+	}
+	suggestion := ""
+	if order := suggestOrder(c.sampleTokens(inputs), c.prefixLen, orderRatioThreshold); order > 0 {
+		suggestion = fmt.Sprintf("; try -prefix %d", order)
+	}
+	msg := fmt.Sprintf("prefix length %d is too high for this corpus: %.2f of prefixes are distinct (threshold %.2f), the model can only regurgitate%s",
+		c.prefixLen, ratio, orderRatioThreshold, suggestion)
+	if strict {
+		return fmt.Errorf("%s: %w", msg, ErrConstraintsUnsatisfied)
+	}
+	fmt.Fprintf(stderr, "WARNING: %s\n", msg)
+	return nil
+}
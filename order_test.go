@@ -0,0 +1,101 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEstimateRatioForOrderExact(t *testing.T) {
+	// tokens: a b a b. Order 1 prefixes: "", a, b, a -> 3 distinct of 4.
+	tokens := []string{"a", "b", "a", "b"}
+	if got := estimateRatioForOrder(tokens, 1); got != 0.75 {
+		t.Errorf("order 1 ratio = %v, want 0.75", got)
+	}
+	// Order 2 prefixes: ("" ""), ("" a), (a b), (b a) -> 4 distinct of 4.
+	if got := estimateRatioForOrder(tokens, 2); got != 1.0 {
+		t.Errorf("order 2 ratio = %v, want 1.0", got)
+	}
+	if got := estimateRatioForOrder(nil, 2); got != 0 {
+		t.Errorf("empty sample ratio = %v, want 0", got)
+	}
+}
+
+func TestEstimateRatioAcrossOrders(t *testing.T) {
+	// A highly repetitive corpus stays low-ratio at order 1 but
+	// approaches 1 as the order climbs past the repetition length.
+	var tokens []string
+	for i := 0; i < 100; i++ {
+		tokens = append(tokens, "the", "quick", "fox")
+	}
+	if r := estimateRatioForOrder(tokens, 1); r > 0.05 {
+		t.Errorf("order 1 ratio on repetitive corpus = %v, want near 0", r)
+	}
+	prev := 0.0
+	for order := 1; order <= 6; order++ {
+		r := estimateRatioForOrder(tokens, order)
+		if r < prev {
+			t.Errorf("ratio decreased from %v to %v at order %d", prev, r, order)
+		}
+		prev = r
+	}
+}
+
+func TestSuggestOrder(t *testing.T) {
+	var tokens []string
+	for i := 0; i < 50; i++ {
+		tokens = append(tokens, "a", "b", "c", "d")
+	}
+	// Orders 1..4 all revisit prefixes heavily, so the largest order
+	// below 8 under the threshold is 7... only if 7 qualifies; on this
+	// cycle of period 4 every order has at most 4+order distinct
+	// prefixes over 200 tokens, far below the threshold.
+	if got := suggestOrder(tokens, 8, orderRatioThreshold); got != 7 {
+		t.Errorf("suggestOrder = %d, want 7", got)
+	}
+	// An all-unique corpus can't support any order.
+	unique := []string{"u1", "u2", "u3", "u4", "u5", "u6"}
+	if got := suggestOrder(unique, 3, orderRatioThreshold); got != 0 {
+		t.Errorf("suggestOrder on unique tokens = %d, want 0", got)
+	}
+}
+
+func TestStrictOrderFailsPathologicalBuild(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "tiny.txt")
+	if err := os.WriteFile(corpus, []byte("one two three four five six seven eight nine ten\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.model")
+
+	code, _, stderr := runCLI("read", "-prefix", "8", "-strict-order", "-out", out, corpus)
+	if code != exitConstraints {
+		t.Fatalf("strict build exited %d, want %d (stderr: %s)", code, exitConstraints, stderr)
+	}
+	if !strings.Contains(stderr, "too high") {
+		t.Errorf("error %q does not explain the order problem", stderr)
+	}
+
+	// Without -strict-order the same build succeeds with a warning.
+	code, _, stderr = runCLI("read", "-prefix", "8", "-out", out, corpus)
+	if code != 0 {
+		t.Fatalf("non-strict build exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "WARNING") {
+		t.Errorf("stderr %q has no prominent warning", stderr)
+	}
+
+	// A healthy order on a repetitive corpus warns about nothing.
+	repetitive := filepath.Join(dir, "rep.txt")
+	if err := os.WriteFile(repetitive, []byte(strings.Repeat("tick tock ", 200)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCLI("read", "-prefix", "1", "-strict-order", "-out", out, repetitive)
+	if code != 0 {
+		t.Fatalf("healthy build exited %d: %s", code, stderr)
+	}
+	if strings.Contains(stderr, "WARNING") {
+		t.Errorf("healthy build warned: %s", stderr)
+	}
+}
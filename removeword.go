@@ -0,0 +1,120 @@
+package markov
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RemoveReport summarizes what RemoveWord excised from the chain.
+type RemoveReport struct {
+	Transitions int // prefix -> word transitions removed
+	Prefixes    int // prefix keys containing the word that were dropped
+	Bridged     int // transitions rerouted around a dropped prefix (bridge mode)
+}
+
+// RemoveWord deletes a word from the chain everywhere: every
+// transition that emits it, and every prefix key containing it. It
+// exists for models whose corpus is gone, so retraining without the
+// word is not an option. Prefixes containing the word are dropped
+// outright; walks that would have entered them dead-end instead.
+func (c *Chain) RemoveWord(word string) RemoveReport {
+	return c.removeWord(word, false)
+}
+
+// RemoveWordBridge is RemoveWord, but instead of letting walks dead-end
+// at a dropped prefix it reconnects each predecessor directly to the
+// dropped prefix's successors with summed counts. This is an
+// approximation: the bridged counts did not co-occur in the corpus, it
+// only preserves connectivity and rough proportions.
+func (c *Chain) RemoveWordBridge(word string) RemoveReport {
+	return c.removeWord(word, true)
+}
+
+func (c *Chain) removeWord(word string, bridge bool) RemoveReport {
+	var rep RemoveReport
+	if bridge {
+		// For each predecessor P that emits the word, the walk would
+		// shift into prefix Q = shift(P, word). Reroute P directly to
+		// Q's successors before both the transition and Q are dropped.
+		for key, counts := range c.chain {
+			if counts[word] == 0 {
+				continue
+			}
+			p := Prefix(strings.Split(key, " "))
+			if len(p) != c.prefixLen {
+				continue // wrong-arity key; nothing sensible to bridge
+			}
+			q := make(Prefix, len(p))
+			copy(q, p)
+			q.Shift(word)
+			for s, n := range c.chain[q.String()] {
+				if s == word {
+					continue
+				}
+				counts[s] += n
+				rep.Bridged++
+			}
+		}
+	}
+	for key, counts := range c.chain {
+		if counts[word] != 0 {
+			delete(counts, word)
+			rep.Transitions++
+		}
+		keyHasWord := false
+		for _, w := range strings.Split(key, " ") {
+			if w == word {
+				keyHasWord = true
+				break
+			}
+		}
+		if keyHasWord || len(counts) == 0 {
+			delete(c.chain, key)
+			if keyHasWord {
+				rep.Prefixes++
+			}
+		}
+	}
+	// The edits above rewrite the chain maps in place, so a tracked
+	// reverse index is rederived rather than patched.
+	c.rebuildReverse()
+	return rep
+}
+
+// runRemoveWord loads a model, excises a word, and writes the cleaned
+// model to -o.
+func runRemoveWord(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark remove-word", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file to read")
+	word := fs.String("word", "", "word to remove from the model")
+	out := fs.String("o", "", "cleaned model file to write")
+	bridge := fs.Bool("bridge", false, "reconnect predecessors of dropped prefixes to their successors (approximate) instead of dead-ending")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" || *word == "" || *out == "" {
+		return &usageError{msg: "remove-word requires -model, -word, and -o"}
+	}
+	c, err := loadModelFile(*model)
+	if err != nil {
+		return err
+	}
+	var rep RemoveReport
+	if *bridge {
+		rep = c.RemoveWordBridge(*word)
+	} else {
+		rep = c.RemoveWord(*word)
+	}
+	if err := atomicWriteFile(*out, c.writeModel); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "removed %q: %d transitions, %d prefixes", *word, rep.Transitions, rep.Prefixes)
+	if *bridge {
+		fmt.Fprintf(stderr, ", %d transitions bridged", rep.Bridged)
+	}
+	fmt.Fprintln(stderr)
+	return nil
+}
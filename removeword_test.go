@@ -0,0 +1,115 @@
+package markov
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chainWithWord trains a prefix-2 chain where "smith" occurs in the
+// middle of a cycle, so it appears both as a suffix and inside keys.
+func chainWithWord(t *testing.T) *Chain {
+	t.Helper()
+	c := NewChain(2)
+	c.Build(strings.NewReader(strings.Repeat("the old smith hammers iron and ", 30)))
+	return c
+}
+
+func assertWordGone(t *testing.T, c *Chain, word string) {
+	t.Helper()
+	for key, counts := range c.chain {
+		for _, w := range strings.Split(key, " ") {
+			if w == word {
+				t.Fatalf("key %q still contains %q", key, word)
+			}
+		}
+		if counts[word] != 0 {
+			t.Fatalf("key %q still has %q as a suffix", key, word)
+		}
+	}
+}
+
+func TestRemoveWordExcisesEverywhere(t *testing.T) {
+	c := chainWithWord(t)
+	rep := c.RemoveWord("smith")
+	if rep.Transitions == 0 || rep.Prefixes == 0 {
+		t.Fatalf("report %+v claims nothing was removed", rep)
+	}
+	assertWordGone(t, c, "smith")
+	// The word must never come back out, whatever the seed.
+	for seed := int64(0); seed < 50; seed++ {
+		rand.Seed(seed)
+		for _, w := range strings.Fields(c.Generate(100)) {
+			if w == "smith" {
+				t.Fatalf("seed %d generated the removed word", seed)
+			}
+		}
+	}
+}
+
+func TestRemoveWordBridgePreservesConnectivity(t *testing.T) {
+	corpus := strings.Repeat("a smith b ", 20)
+
+	plain := NewChain(1)
+	plain.Build(strings.NewReader(corpus))
+	plain.RemoveWord("smith")
+	rand.Seed(1)
+	if got := plain.Generate(50); strings.Contains(got, "b") {
+		t.Fatalf("without -bridge the walk should dead-end before %q: %q", "b", got)
+	}
+
+	bridged := NewChain(1)
+	bridged.Build(strings.NewReader(corpus))
+	rep := bridged.RemoveWordBridge("smith")
+	if rep.Bridged == 0 {
+		t.Fatalf("report %+v claims nothing was bridged", rep)
+	}
+	assertWordGone(t, bridged, "smith")
+	rand.Seed(1)
+	if got := bridged.Generate(50); !strings.Contains(got, "b") {
+		t.Fatalf("bridged walk never reached %q: %q", "b", got)
+	}
+}
+
+func TestRemoveWordReportCounts(t *testing.T) {
+	c := NewChain(1)
+	c.addSuffix("\"\"", "x", 1)
+	c.addSuffix("x", "smith", 2)
+	c.addSuffix("smith", "y", 1)
+	c.addSuffix("y", "x", 1)
+	rep := c.RemoveWord("smith")
+	if rep.Transitions != 1 || rep.Prefixes != 1 || rep.Bridged != 0 {
+		t.Errorf("report = %+v, want 1 transition, 1 prefix, 0 bridged", rep)
+	}
+}
+
+func TestRemoveWordCLI(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("a smith b ", 20)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	cleaned := filepath.Join(dir, "cleaned.model")
+	code, _, stderr := runCLI("remove-word", "-model", model, "-word", "smith", "-bridge", "-o", cleaned)
+	if code != 0 {
+		t.Fatalf("remove-word exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "bridged") {
+		t.Errorf("report %q does not mention bridging", stderr)
+	}
+	loaded, err := loadTextChain(cleaned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWordGone(t, loaded, "smith")
+
+	if code, _, _ := runCLI("remove-word", "-model", model); code != exitUsage {
+		t.Errorf("remove-word without -word/-o exited %d, want %d", code, exitUsage)
+	}
+}
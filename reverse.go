@@ -0,0 +1,116 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The reverse index turns the chain around: for every window the
+// forward walk can shift into, it records which words the shift
+// dropped on the left, so a walk can extend text backwards from a
+// target phrase. It is exactly derivable from the forward chain, so
+// the model formats carry only a flag and the loaders rebuild it.
+
+// TrackReverse makes Build (and the model loaders) maintain the
+// reverse index alongside the forward chain, for GenerateEndingWith.
+// Off by default so memory does not double for chains that never walk
+// backwards. Recorded in the model header like the other options.
+func (c *Chain) TrackReverse() {
+	if c.reverse == nil {
+		c.reverse = make(map[string]map[string]float64)
+	}
+}
+
+// noteReverse records one transition backwards: accepting word shifts
+// the window key to key[1:]+word, and the weight is credited to the
+// word the shift dropped. A no-op unless TrackReverse is on.
+func (c *Chain) noteReverse(key, word string, n float64) {
+	if c.reverse == nil {
+		return
+	}
+	parts := strings.Split(key, " ")
+	rev := strings.Join(append(parts[1:], word), " ")
+	m := c.reverse[rev]
+	if m == nil {
+		m = make(map[string]float64)
+		c.reverse[rev] = m
+	}
+	m[c.internWord(parts[0])] += n
+}
+
+// rebuildReverse rederives the reverse index from the forward chain,
+// for editors (remove-word) that rewrite the chain maps in place.
+func (c *Chain) rebuildReverse() {
+	if c.reverse == nil {
+		return
+	}
+	c.reverse = make(map[string]map[string]float64, len(c.chain))
+	for key, counts := range c.chain {
+		for w, n := range counts {
+			c.noteReverse(key, w, n)
+		}
+	}
+}
+
+// GenerateEndingWith generates text that ends with the given phrase,
+// walking the reverse index backwards from it and returning the words
+// in forward order. The walk stops at the word limit n, at a window
+// nothing is recorded to precede, or at the corpus start; a phrase
+// longer than the prefix window may push the result past n by its own
+// length, since the phrase is never truncated. A phrase whose words
+// the model never saw in that position is an ErrUnknownPrefix.
+func (c *Chain) GenerateEndingWith(end string, n int) (string, error) {
+	if c.reverse == nil {
+		return "", fmt.Errorf("model carries no reverse index: build with TrackReverse (or 'read -reverse') first")
+	}
+	fields := strings.Fields(end)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty ending phrase")
+	}
+	var window []string
+	var out []string
+	if len(fields) >= c.prefixLen {
+		// The walk anchors at the window opening the phrase; the rest of
+		// the phrase rides along unchanged.
+		window = fields[:c.prefixLen]
+		if _, ok := c.reverse[strings.Join(window, " ")]; !ok {
+			return "", fmt.Errorf("%w: nothing in the model ends with %q", ErrUnknownPrefix, end)
+		}
+		out = append(out, fields...)
+	} else {
+		// A short phrase matches every window whose tail is the phrase;
+		// one is drawn weighted by how often each was walked into.
+		tail := " " + strings.Join(fields, " ")
+		candidates := make(map[string]float64)
+		for key, counts := range c.reverse {
+			if !strings.HasSuffix(key, tail) {
+				continue
+			}
+			for _, weight := range counts {
+				candidates[key] += weight
+			}
+		}
+		if len(candidates) == 0 {
+			return "", fmt.Errorf("%w: nothing in the model ends with %q", ErrUnknownPrefix, end)
+		}
+		window = strings.Split(weightedChoice(c.rng, candidates), " ")
+		for _, w := range window {
+			if w != startToken {
+				out = append(out, w)
+			}
+		}
+	}
+	for len(out) < n {
+		choices := c.reverse[strings.Join(window, " ")]
+		if len(choices) == 0 {
+			break
+		}
+		prev := weightedChoice(c.rng, choices)
+		if prev == startToken {
+			break
+		}
+		out = append([]string{prev}, out...)
+		window = append([]string{prev}, window[:len(window)-1]...)
+	}
+	return c.joinTokens(out), nil
+}
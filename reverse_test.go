@@ -0,0 +1,132 @@
+package markov
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReverseIndexRecordsPredecessors(t *testing.T) {
+	c := NewChain(2)
+	c.TrackReverse()
+	if err := c.Build(strings.NewReader("x y z")); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.reverse["y z"]["x"]; got != 1 {
+		t.Errorf(`reverse["y z"]["x"] = %v, want 1`, got)
+	}
+	if got := c.reverse["x y"][startToken]; got != 1 {
+		t.Errorf(`reverse["x y"] should credit the sentinel at the corpus start, got %v`, c.reverse["x y"])
+	}
+}
+
+func TestGenerateEndingWithEndsWithPhrase(t *testing.T) {
+	c := NewChain(2)
+	c.TrackReverse()
+	text := "the old wolf sleeps in the forest and the young wolf sleeps by the river"
+	if err := c.Build(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(1); seed <= 5; seed++ {
+		c.SetRandom(rand.NewSource(seed))
+		got, err := c.GenerateEndingWith("wolf sleeps", 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(got, "wolf sleeps") {
+			t.Errorf("seed %d: %q does not end with the phrase", seed, got)
+		}
+		if n := len(strings.Fields(got)); n > 8 {
+			t.Errorf("seed %d: %d words generated, limit was 8", seed, n)
+		}
+	}
+}
+
+func TestGenerateEndingWithShortPhrase(t *testing.T) {
+	// "river" is shorter than the prefix window, so the walk first picks
+	// a window ending in it.
+	c := NewChain(2)
+	c.TrackReverse()
+	if err := c.Build(strings.NewReader("down by the river the water runs to the river")); err != nil {
+		t.Fatal(err)
+	}
+	c.SetRandom(rand.NewSource(1))
+	got, err := c.GenerateEndingWith("river", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(got, "river") {
+		t.Errorf("%q does not end with the word", got)
+	}
+	if n := len(strings.Fields(got)); n > 6 {
+		t.Errorf("%d words generated, limit was 6", n)
+	}
+}
+
+func TestGenerateEndingWithUnknownWord(t *testing.T) {
+	c := NewChain(1)
+	c.TrackReverse()
+	if err := c.Build(strings.NewReader("a b c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GenerateEndingWith("zebra", 5); !errors.Is(err, ErrUnknownPrefix) {
+		t.Errorf("unknown ending word returned %v, want ErrUnknownPrefix", err)
+	}
+}
+
+func TestGenerateEndingWithoutReverseIndex(t *testing.T) {
+	c := NewChain(1)
+	if err := c.Build(strings.NewReader("a b c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GenerateEndingWith("b", 5); err == nil || !strings.Contains(err.Error(), "reverse") {
+		t.Errorf("chain without a reverse index returned %v, want an error naming it", err)
+	}
+}
+
+func TestReverseIndexSurvivesReload(t *testing.T) {
+	c := NewChain(2)
+	c.TrackReverse()
+	if err := c.Build(strings.NewReader("x y z x y w")); err != nil {
+		t.Fatal(err)
+	}
+	loaded := reload(t, c, c.writeModel, readModelStream)
+	if len(loaded.reverse) == 0 {
+		t.Fatal("reloaded model lost the reverse index")
+	}
+	if !c.Equal(loaded) {
+		t.Error("text round trip with a reverse index: Chain.Equal is false")
+	}
+}
+
+func TestEndFlag(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	text := "the old wolf sleeps in the forest and the young wolf sleeps by the river"
+	if err := os.WriteFile(corpus, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-reverse", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-end", "wolf sleeps", "-seed", "1", "-words", "8")
+	if code != 0 {
+		t.Fatalf("generate -end exited %d: %s", code, stderr)
+	}
+	if got := strings.TrimSpace(stdout); !strings.HasSuffix(got, "wolf sleeps") {
+		t.Errorf("output %q does not end with the phrase", got)
+	}
+
+	// A model built without -reverse cannot walk backwards.
+	plain := filepath.Join(dir, "plain.model")
+	if code, _, stderr := runCLI("read", "-out", plain, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCLI("generate", "-model", plain, "-end", "wolf sleeps"); code == 0 || !strings.Contains(stderr, "reverse") {
+		t.Errorf("generate -end on a plain model exited %d (%s), want a failure naming the reverse index", code, stderr)
+	}
+}
@@ -0,0 +1,164 @@
+package markov
+
+import (
+	"container/list"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Freezing a cumulative-weight table for every prefix up front costs
+// more memory than the chain itself on big models, and most prefixes
+// are never visited. The sample cache builds tables lazily on first
+// visit and keeps only the hottest ones: a size-capped LRU, safe for
+// concurrent generations in serve mode.
+
+// sampleTable is one prefix's suffix distribution frozen into sorted
+// words with cumulative weights, so a draw is one binary search
+// instead of a map walk. Integral distributions freeze exact int64
+// prefix sums; float ones keep running float sums plus the compensated
+// total, mirroring pickWeighted's two paths step for step.
+type sampleTable struct {
+	words  []string
+	cumInt []int64   // exact prefix sums when every weight is integral
+	cum    []float64 // running sums in word order otherwise
+	total  float64   // compensated total for the float path
+}
+
+// newSampleTable freezes a suffix map. Words are sorted and the
+// cumulative sums accumulate in that order, exactly as pickWeighted
+// sums them, so both samplers make identical draws from the same seed.
+func newSampleTable(counts map[string]float64) *sampleTable {
+	t := &sampleTable{words: make([]string, 0, len(counts))}
+	for w := range counts {
+		t.words = append(t.words, w)
+	}
+	sort.Strings(t.words)
+	integral := true
+	for _, w := range t.words {
+		if n := counts[w]; n != math.Trunc(n) || n < 0 || n >= maxExactWeight {
+			integral = false
+			break
+		}
+	}
+	if integral {
+		t.cumInt = make([]int64, len(t.words))
+		var total int64
+		for i, w := range t.words {
+			total += int64(counts[w])
+			t.cumInt[i] = total
+		}
+		return t
+	}
+	t.cum = make([]float64, len(t.words))
+	total := 0.0
+	for i, w := range t.words {
+		total += counts[w]
+		t.cum[i] = total
+	}
+	t.total = kahanSum(len(t.words), func(i int) float64 { return counts[t.words[i]] })
+	return t
+}
+
+// pick draws one word, consuming exactly the draw pickWeighted would
+// consume for the same distribution.
+func (t *sampleTable) pick(rng *rand.Rand) string {
+	if t.cumInt != nil {
+		total := t.cumInt[len(t.cumInt)-1]
+		if total <= 0 {
+			return t.words[len(t.words)-1]
+		}
+		r := randInt63n(rng, total)
+		i := sort.Search(len(t.cumInt), func(i int) bool { return t.cumInt[i] > r })
+		return t.words[i]
+	}
+	r := randFloat64(rng) * t.total
+	i := sort.Search(len(t.cum), func(i int) bool { return t.cum[i] > r })
+	if i == len(t.words) {
+		i = len(t.words) - 1
+	}
+	return t.words[i]
+}
+
+// sampleCache is an LRU of sample tables keyed by prefix, capped at a
+// fixed number of entries.
+type sampleCache struct {
+	mu      sync.Mutex
+	maxSize int
+	order   *list.List // front = most recently used; values are *cacheEntry
+	items   map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	table *sampleTable
+}
+
+func newSampleCache(maxSize int) *sampleCache {
+	return &sampleCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[string]*list.Element, maxSize),
+	}
+}
+
+// table returns the frozen table for the counts under the given key,
+// building and caching it on a miss and evicting the least recently
+// used tables beyond the cap.
+func (sc *sampleCache) table(key string, counts map[string]float64) *sampleTable {
+	sc.mu.Lock()
+	if el, ok := sc.items[key]; ok {
+		sc.order.MoveToFront(el)
+		t := el.Value.(*cacheEntry).table
+		sc.mu.Unlock()
+		return t
+	}
+	sc.mu.Unlock()
+
+	// Build outside the lock: freezing a big distribution should not
+	// stall every other generation.
+	t := newSampleTable(counts)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if el, ok := sc.items[key]; ok {
+		// Another generation built it concurrently; keep theirs.
+		sc.order.MoveToFront(el)
+		return el.Value.(*cacheEntry).table
+	}
+	sc.items[key] = sc.order.PushFront(&cacheEntry{key: key, table: t})
+	for sc.order.Len() > sc.maxSize {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.items, oldest.Value.(*cacheEntry).key)
+	}
+	return t
+}
+
+// len reports the number of cached tables.
+func (sc *sampleCache) len() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.order.Len()
+}
+
+// EnableSampleCache turns on lazy cached sampling tables for this
+// chain, keeping at most maxEntries of them. Entries smaller than 1
+// disable the cache again.
+func (c *Chain) EnableSampleCache(maxEntries int) {
+	if maxEntries < 1 {
+		c.samples = nil
+		return
+	}
+	c.samples = newSampleCache(maxEntries)
+}
+
+// choose picks the next word for the prefix's suffix distribution,
+// through the sample cache when one is enabled.
+func (c *Chain) choose(key string, counts map[string]float64) string {
+	if c.samples != nil {
+		return c.samples.table(key, counts).pick(c.rng)
+	}
+	return weightedChoice(c.rng, counts)
+}
@@ -0,0 +1,104 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSampleCacheMatchesWeightedChoice(t *testing.T) {
+	plain := benchChain(t)
+	cached := benchChain(t)
+	cached.EnableSampleCache(1 << 10)
+	// Both samplers make pickWeighted's draw over the same sorted
+	// cumulative order, so seeded runs must be identical.
+	for seed := int64(1); seed <= 5; seed++ {
+		rand.Seed(seed)
+		want := plain.Generate(300)
+		rand.Seed(seed)
+		got := cached.Generate(300)
+		if got != want {
+			t.Fatalf("seed %d: cached output diverges\ncached: %q\nplain:  %q", seed, got, want)
+		}
+	}
+}
+
+func TestSampleCacheStaysWithinCap(t *testing.T) {
+	c := benchChain(t)
+	const maxEntries = 4
+	c.EnableSampleCache(maxEntries)
+	rand.Seed(1)
+	out := c.Generate(2000)
+	if len(strings.Fields(out)) == 0 {
+		t.Fatal("no output generated with the cache enabled")
+	}
+	if got := c.samples.len(); got > maxEntries {
+		t.Errorf("cache holds %d tables, cap is %d", got, maxEntries)
+	}
+}
+
+func TestSampleCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	sc := newSampleCache(2)
+	counts := map[string]float64{"x": 1}
+	sc.table("a", counts)
+	sc.table("b", counts)
+	sc.table("a", counts) // refresh a, so c should evict b
+	sc.table("c", counts)
+	sc.mu.Lock()
+	_, hasA := sc.items["a"]
+	_, hasB := sc.items["b"]
+	sc.mu.Unlock()
+	if !hasA || hasB {
+		t.Errorf("after refresh+insert: a cached %v, b cached %v; want a kept, b evicted", hasA, hasB)
+	}
+}
+
+func TestSampleCacheConcurrentGeneration(t *testing.T) {
+	c := benchChain(t)
+	c.EnableSampleCache(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if c.Generate(100) == "" {
+					t.Error("empty generation under concurrency")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func benchGenerate(b *testing.B, c *Chain) {
+	b.Helper()
+	rand.Seed(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Generate(200)
+	}
+}
+
+func BenchmarkGenerateUncached(b *testing.B) {
+	benchGenerate(b, benchChain(b))
+}
+
+func BenchmarkGenerateWarmCache(b *testing.B) {
+	c := benchChain(b)
+	c.EnableSampleCache(1 << 14)
+	c.Generate(5000) // warm the hot prefixes
+	benchGenerate(b, c)
+}
+
+func BenchmarkGenerateColdCache(b *testing.B) {
+	c := benchChain(b)
+	rand.Seed(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.EnableSampleCache(1 << 14) // fresh, empty cache every run
+		c.Generate(200)
+	}
+}
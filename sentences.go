@@ -0,0 +1,51 @@
+package markov
+
+import "sort"
+
+// Plain generation starts at the canonical sentinel prefix and stops
+// dead at the word budget, so output for anything user-facing begins
+// and ends mid-sentence. Sentence mode fixes both ends: Build records
+// which prefixes open a sentence (the start of input and every word
+// after terminal punctuation), and Generate starts from one of those
+// and runs past the budget until it lands on a sentence-ending word.
+
+// TrackSentenceStarts makes Build record sentence-opening prefixes,
+// weighted by how often each opened a sentence in the corpus. The
+// recorded starts travel in the model header so generate mode can use
+// them without re-reading the corpus.
+func (c *Chain) TrackSentenceStarts() {
+	if c.starts == nil {
+		c.starts = make(map[string]float64)
+	}
+}
+
+// addSentenceStart records that key opened a sentence n more times.
+// Like addSuffix it is the key-level core shared with the model
+// loaders.
+func (c *Chain) addSentenceStart(key string, n float64) {
+	if c.starts == nil {
+		c.starts = make(map[string]float64)
+	}
+	c.starts[key] += n
+}
+
+// sentenceStartKeys returns the recorded start keys in sorted order,
+// for the model writers.
+func (c *Chain) sentenceStartKeys() []string {
+	keys := make([]string, 0, len(c.starts))
+	for key := range c.starts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pickSentenceStart draws one recorded start key with probability
+// proportional to how often it opened a sentence, or "" when the chain
+// recorded none.
+func (c *Chain) pickSentenceStart() string {
+	if len(c.starts) == 0 {
+		return ""
+	}
+	return weightedChoice(c.rng, c.starts)
+}
@@ -0,0 +1,114 @@
+package markov
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// threeSentenceChain tracks sentence starts over a corpus of three
+// sentences that all open with a capitalized word.
+func threeSentenceChain() *Chain {
+	c := NewChain(2)
+	c.TrackSentenceStarts()
+	c.Build(strings.NewReader("The cat sat down. The dog ran away. The bird flew home."))
+	return c
+}
+
+func TestSentenceModeStartsAndEnds(t *testing.T) {
+	c := threeSentenceChain()
+	for seed := int64(1); seed <= 5; seed++ {
+		c.SetRandom(rand.NewSource(seed))
+		res := c.GenerateWithOptions(GenerateOptions{Words: 4, Sentences: true})
+		fields := strings.Fields(res.Text)
+		if len(fields) == 0 {
+			t.Fatalf("seed %d: no output", seed)
+		}
+		first, _ := utf8.DecodeRuneInString(fields[0])
+		if !unicode.IsUpper(first) {
+			t.Errorf("seed %d: output starts with %q, want a capitalized sentence opener", seed, fields[0])
+		}
+		if last := fields[len(fields)-1]; !endsSentence(last) {
+			t.Errorf("seed %d: output ends with %q, want terminal punctuation", seed, last)
+		}
+	}
+}
+
+func TestSentenceModeCapsWithoutPunctuation(t *testing.T) {
+	// No sentence ever ends, so the hard cap at twice the word target is
+	// the only way out.
+	c := NewChain(2)
+	c.TrackSentenceStarts()
+	c.Build(strings.NewReader(strings.Repeat("alpha beta gamma delta ", 5)))
+	c.SetRandom(rand.NewSource(1))
+	res := c.GenerateWithOptions(GenerateOptions{Words: 5, Sentences: true})
+	fields := strings.Fields(res.Text)
+	if len(fields) != 10 {
+		t.Errorf("got %d words, want the 2x cap of 10: %q", len(fields), res.Text)
+	}
+	if endsSentence(fields[len(fields)-1]) {
+		t.Errorf("punctuation appeared from nowhere: %q", res.Text)
+	}
+}
+
+func TestSentenceModeSingleSentence(t *testing.T) {
+	// One sentence, one start, one path: the output is the corpus, ending
+	// at the dead end even though the budget allows more.
+	c := NewChain(2)
+	c.TrackSentenceStarts()
+	c.Build(strings.NewReader("Only one sentence here."))
+	res := c.GenerateWithOptions(GenerateOptions{Words: 10, Sentences: true})
+	if res.Text != "Only one sentence here." {
+		t.Errorf("got %q, want the corpus back", res.Text)
+	}
+}
+
+func TestSentenceModeWithoutRecordedStarts(t *testing.T) {
+	// A chain that never tracked starts falls back to the sentinel start,
+	// so sentence mode still produces output.
+	c := NewChain(2)
+	c.Build(strings.NewReader("The cat sat down. The dog ran away."))
+	c.SetRandom(rand.NewSource(1))
+	res := c.GenerateWithOptions(GenerateOptions{Words: 4, Sentences: true})
+	if res.Words == 0 {
+		t.Error("no output from an untracked chain in sentence mode")
+	}
+}
+
+func TestSentenceStartsSurviveReload(t *testing.T) {
+	c := threeSentenceChain()
+	if got := c.starts["The cat"] + c.starts["The dog"] + c.starts["The bird"]; got != 3 {
+		t.Fatalf("recorded starts %v, want one each for the three sentences", c.starts)
+	}
+	loaded := reload(t, c, c.writeModel, readModelStream)
+	if !c.Equal(loaded) {
+		t.Error("sentence starts lost in the text round trip")
+	}
+}
+
+func TestSentencesFlag(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("The cat sat down. The dog ran away. The bird flew home."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.txt")
+	if code, _, stderr := runCLI("read", "-prefix", "2", "-sentences", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-sentences", "-seed", "1", "-words", "4")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		t.Fatal("no output")
+	}
+	if fields[0] != "The" || !endsSentence(fields[len(fields)-1]) {
+		t.Errorf("output %q does not span whole sentences", stdout)
+	}
+}
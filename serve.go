@@ -0,0 +1,263 @@
+package markov
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serve mode exposes generation over HTTP: GET /generate?words=N
+// returns generated text, ?model=name picks one of the loaded models,
+// ?start=phrase continues from a phrase, and ?format=json wraps the
+// text in the same JSON object generate -json emits. Every request
+// gets a random ID, returned in the X-Request-ID response header and
+// stamped on the access log line and (with -trace) on every generation
+// trace line, so a slow or weird generation can be correlated back to
+// the request that caused it.
+type server struct {
+	models   map[string]*Chain
+	def      string        // model served when the query names none
+	maxWords int           // per-request cap on ?words=; 0 means uncapped
+	maxTime  time.Duration // per-request latency cap; 0 means uncapped
+	logger   *slog.Logger
+
+	// trace, when non-nil, receives one line per generated word. The
+	// mutex serializes concurrent handlers writing to the same file.
+	traceMu sync.Mutex
+	trace   io.Writer
+}
+
+// statusWriter wraps the ResponseWriter so the access log can report
+// status and bytes after the handler finishes, without any logging on
+// the handler path itself.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// newRequestID returns a short random hex ID for correlating an access
+// log line with its generation trace.
+func newRequestID() string {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id := newRequestID()
+	sw := &statusWriter{ResponseWriter: w}
+	sw.Header().Set("X-Request-ID", id)
+	words := s.handle(sw, r, id)
+	// The log line is emitted after the response is complete, so
+	// logging never blocks the handler path.
+	s.logger.Info("request",
+		"id", id,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", sw.status,
+		"duration", time.Since(start),
+		"words", words,
+		"bytes", sw.bytes,
+		"client", clientIP(r),
+	)
+}
+
+// clientIP strips the ephemeral port from the remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handle serves one generation request and reports how many words it
+// produced, for the access log.
+func (s *server) handle(w http.ResponseWriter, r *http.Request, id string) int {
+	if r.URL.Path != "/generate" {
+		http.NotFound(w, r)
+		return 0
+	}
+	q := r.URL.Query()
+	name := q.Get("model")
+	if name == "" {
+		name = s.def
+	}
+	c, ok := s.models[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown model %q", name), http.StatusBadRequest)
+		return 0
+	}
+	words := 100
+	if s.maxWords > 0 && words > s.maxWords {
+		words = s.maxWords
+	}
+	if v := q.Get("words"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "words must be a non-negative integer", http.StatusBadRequest)
+			return 0
+		}
+		if s.maxWords > 0 && n > s.maxWords {
+			http.Error(w, fmt.Sprintf("words must be at most %d", s.maxWords), http.StatusBadRequest)
+			return 0
+		}
+		words = n
+	}
+	format := q.Get("format")
+	switch format {
+	case "", "text", "json":
+	default:
+		http.Error(w, `format must be "text" or "json"`, http.StatusBadRequest)
+		return 0
+	}
+	// The latency cap is not an error: a run that hits it returns what
+	// it produced, with timedOut set in the JSON metadata.
+	opts := GenerateOptions{Words: words, Start: q.Get("start"), MaxDuration: s.maxTime}
+	if s.trace != nil {
+		step := 0
+		opts.OnWord = func(word string) {
+			temp := opts.TemperatureFor(step)
+			step++
+			s.traceMu.Lock()
+			fmt.Fprintf(s.trace, "%s step=%d word=%q temp=%g\n", id, step, word, temp)
+			s.traceMu.Unlock()
+		}
+	}
+	// Each request generates through its own shallow copy of the chain
+	// with its own rand.Rand, so concurrent walks over the shared
+	// (read-only) maps never contend on or interleave one stream.
+	cc := *c
+	cc.SetRandom(rand.NewSource(rand.Int63()))
+	res := cc.GenerateWithOptions(opts)
+	text := res.Text
+	if opts.Start != "" {
+		// The phrase leads the output, as in generate -start.
+		if text == "" {
+			text = opts.Start
+		} else {
+			text = opts.Start + cc.joinWith + text
+		}
+	}
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		emitGenerated(w, true, text, res.Elapsed, res.TimedOut, res.StoppedEarly)
+		return res.Words
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, text)
+	return res.Words
+}
+
+// parseServeModels parses the -model list for serve mode: each entry
+// is name=path, or a bare path served under its base name without the
+// extension. The first entry is the default model.
+func parseServeModels(spec string) (models map[string]string, def string, err error) {
+	models = make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		name, path, found := strings.Cut(entry, "=")
+		if !found {
+			path = entry
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if name == "" || path == "" {
+			return nil, "", fmt.Errorf("model entry %q must be name=path or a path", entry)
+		}
+		if _, dup := models[name]; dup {
+			return nil, "", fmt.Errorf("model name %q given twice", name)
+		}
+		models[name] = path
+		if def == "" {
+			def = name
+		}
+	}
+	return models, def, nil
+}
+
+// runServe loads the models and serves them over HTTP until killed.
+func runServe(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file(s) to serve, comma-separated name=path entries (a bare path serves under its base name); the first is the default")
+	listen := fs.String("listen", ":8080", "address to listen on")
+	maxWords := fs.Int("max-words", 1000, "per-request cap on ?words=; 0 means uncapped")
+	maxTime := fs.Duration("max-time", 0, "hard cap on generation time per request (e.g. 200ms); 0 means no cap")
+	trace := fs.String("trace", "", "file to append per-word generation traces to, keyed by request ID")
+	sampleCache := fs.Int("sample-cache", 4096, "max cached sampling tables for hot prefixes; 0 disables the cache")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" {
+		return &usageError{msg: "serve mode requires -model"}
+	}
+	if *maxWords < 0 {
+		return &usageError{msg: "-max-words must not be negative"}
+	}
+	if *maxTime < 0 {
+		return &usageError{msg: "-max-time must not be negative"}
+	}
+	if *sampleCache < 0 {
+		return &usageError{msg: "-sample-cache must not be negative"}
+	}
+	paths, def, err := parseServeModels(*model)
+	if err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	chains := make(map[string]*Chain, len(paths))
+	for name, path := range paths {
+		c, err := loadModelFile(path)
+		if err != nil {
+			return err
+		}
+		c.EnableSampleCache(*sampleCache)
+		chains[name] = c
+	}
+	srv := &server{models: chains, def: def, maxWords: *maxWords, maxTime: *maxTime, logger: slog.New(slog.NewTextHandler(stderr, nil))}
+	if *trace != "" {
+		f, err := os.OpenFile(*trace, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return &writeError{path: *trace, err: err}
+		}
+		defer f.Close()
+		srv.trace = f
+	}
+	rand.Seed(time.Now().UnixNano())
+	names := make([]string, 0, len(chains))
+	for name := range chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(stderr, "serving %s on %s (default %s)\n", strings.Join(names, ", "), *listen, def)
+	return http.ListenAndServe(*listen, srv)
+}
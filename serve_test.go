@@ -0,0 +1,253 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer builds a server over a tiny chain, with the access log
+// going to logBuf and traces (when traceBuf is non-nil) to traceBuf.
+func testServer(t *testing.T, logBuf, traceBuf *bytes.Buffer) *server {
+	t.Helper()
+	c := NewChain(1)
+	c.Build(strings.NewReader(strings.Repeat("tick tock ", 20)))
+	s := &server{
+		models: map[string]*Chain{"m": c},
+		def:    "m",
+		logger: slog.New(slog.NewTextHandler(logBuf, nil)),
+	}
+	if traceBuf != nil {
+		s.trace = traceBuf
+	}
+	return s
+}
+
+func TestServeRequestIDInHeaderLogAndTrace(t *testing.T) {
+	var logBuf, traceBuf bytes.Buffer
+	ts := httptest.NewServer(testServer(t, &logBuf, &traceBuf))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/generate?words=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, body %q", resp.StatusCode, body)
+	}
+	if len(strings.Fields(string(body))) == 0 {
+		t.Error("no words generated")
+	}
+	id := resp.Header.Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("no X-Request-ID header on the response")
+	}
+	if !strings.Contains(logBuf.String(), id) {
+		t.Errorf("access log does not mention request ID %s:\n%s", id, logBuf.String())
+	}
+	if !strings.Contains(traceBuf.String(), id) {
+		t.Errorf("trace lines do not carry request ID %s:\n%s", id, traceBuf.String())
+	}
+}
+
+func TestServeRequestIDsAreDistinct(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	ids := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=2", nil))
+		id := rec.Header().Get("X-Request-ID")
+		if ids[id] {
+			t.Fatalf("request ID %s repeated", id)
+		}
+		ids[id] = true
+	}
+}
+
+func TestServeLogsBadRequests(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=nope", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(logBuf.String(), "status=400") {
+		t.Errorf("access log does not record the 400:\n%s", logBuf.String())
+	}
+}
+
+func TestServeModelSelection(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	other := NewChain(1)
+	other.Build(strings.NewReader(strings.Repeat("ding dong ", 20)))
+	s.models["bells"] = other
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?model=bells&words=10", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	for _, w := range strings.Fields(rec.Body.String()) {
+		if w != "ding" && w != "dong" {
+			t.Fatalf("word %q is not from the selected model", w)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?model=nope", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "nope") {
+		t.Errorf("unknown model returned %d %q, want a 400 naming it", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeJSONFormat(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=5&format=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var meta struct {
+		Text  string `json:"text"`
+		Words int    `json:"words"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("bad JSON body %q: %v", rec.Body.String(), err)
+	}
+	if meta.Words == 0 || meta.Text == "" {
+		t.Errorf("empty JSON result: %+v", meta)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown format returned %d, want 400", rec.Code)
+	}
+}
+
+func TestServeWordCap(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	s.maxWords = 5
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=50", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "5") {
+		t.Errorf("over-cap request returned %d %q, want a 400 naming the cap", rec.Code, rec.Body.String())
+	}
+	// Without an explicit count the default is clamped, not refused.
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if n := len(strings.Fields(rec.Body.String())); n > 5 {
+		t.Errorf("default request produced %d words, cap was 5", n)
+	}
+}
+
+func TestServeLatencyCap(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	s.maxTime = time.Nanosecond
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=5000&format=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	var meta struct {
+		TimedOut bool `json:"timedOut"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("bad JSON body %q: %v", rec.Body.String(), err)
+	}
+	if !meta.TimedOut {
+		t.Error("an immediate deadline did not cut the run short")
+	}
+}
+
+func TestServeStartParam(t *testing.T) {
+	var logBuf bytes.Buffer
+	s := testServer(t, &logBuf, nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/generate?words=5&start=tick", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if !strings.HasPrefix(rec.Body.String(), "tick") {
+		t.Errorf("body %q does not lead with the start phrase", rec.Body.String())
+	}
+}
+
+func TestServeConcurrentRequests(t *testing.T) {
+	var logBuf bytes.Buffer
+	ts := httptest.NewServer(testServer(t, &logBuf, nil))
+	defer ts.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(ts.URL + "/generate?words=50")
+			if err != nil {
+				errs <- err
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				errs <- fmt.Errorf("status %d, body %q", resp.StatusCode, body)
+				return
+			}
+			if len(strings.Fields(string(body))) == 0 {
+				errs <- fmt.Errorf("empty body")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestParseServeModels(t *testing.T) {
+	models, def, err := parseServeModels("austen=a.model,dir/poe.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != "austen" {
+		t.Errorf("default = %q, want austen", def)
+	}
+	if models["austen"] != "a.model" || models["poe"] != "dir/poe.bin" {
+		t.Errorf("parsed %v", models)
+	}
+	for _, bad := range []string{"a=x,a=y", "=x", "a="} {
+		if _, _, err := parseServeModels(bad); err == nil {
+			t.Errorf("parseServeModels(%q) accepted malformed input", bad)
+		}
+	}
+}
+
+func TestServeRequiresModel(t *testing.T) {
+	if code, _, _ := runCLI("serve"); code != exitUsage {
+		t.Errorf("serve without -model exited %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,121 @@
+package markov
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Skip patterns are gitignore-style: `*` and `?` match within one path
+// segment, `**` spans any number of segments, and a pattern without a
+// slash matches against every segment of the path (so `*.min.js` skips
+// minified files at any depth). filepath.Match alone cannot express
+// `**`, which is why the matcher lives here as its own unit.
+
+// matchSkipPattern reports whether the pattern matches the relative
+// path. Both sides are normalized to forward slashes first, so
+// patterns and paths written with either separator behave identically
+// on every platform (the syntax has no backslash escapes to collide
+// with).
+func matchSkipPattern(pattern, path string) bool {
+	pattern = strings.ReplaceAll(pattern, `\`, "/")
+	path = strings.ReplaceAll(path, `\`, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, seg := range strings.Split(path, "/") {
+			if matchSegment(pattern, seg) {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+// matchSegments matches a slash-split pattern against slash-split path
+// segments, with `**` consuming zero or more of them.
+func matchSegments(pat, path []string) bool {
+	if len(pat) == 0 {
+		return len(path) == 0
+	}
+	if pat[0] == "**" {
+		if matchSegments(pat[1:], path) {
+			return true
+		}
+		return len(path) > 0 && matchSegments(pat, path[1:])
+	}
+	if len(path) == 0 {
+		return false
+	}
+	return matchSegment(pat[0], path[0]) && matchSegments(pat[1:], path[1:])
+}
+
+// matchSegment matches one pattern segment against one path segment,
+// with `*` matching any run of characters and `?` exactly one.
+func matchSegment(pat, s string) bool {
+	for len(pat) > 0 {
+		p, rest := pat[0], pat[1:]
+		switch p {
+		case '*':
+			for i := 0; i <= len(s); i++ {
+				if matchSegment(rest, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			s = s[size:]
+		default:
+			if s == "" || s[0] != p {
+				return false
+			}
+			s = s[1:]
+		}
+		pat = rest
+	}
+	return s == ""
+}
+
+// skipList holds the parsed -skip patterns. A nil list matches nothing.
+type skipList struct {
+	patterns []string
+}
+
+// parseSkipList splits a comma-separated -skip value into a list,
+// dropping empty entries. An empty value yields nil.
+func parseSkipList(s string) *skipList {
+	var l skipList
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			l.patterns = append(l.patterns, p)
+		}
+	}
+	if len(l.patterns) == 0 {
+		return nil
+	}
+	return &l
+}
+
+// list returns the patterns, nil for a nil list.
+func (l *skipList) list() []string {
+	if l == nil {
+		return nil
+	}
+	return l.patterns
+}
+
+// match reports whether any pattern matches the slash-separated
+// relative path.
+func (l *skipList) match(rel string) bool {
+	if l == nil {
+		return false
+	}
+	for _, p := range l.patterns {
+		if matchSkipPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
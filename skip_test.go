@@ -0,0 +1,47 @@
+package markov
+
+import "testing"
+
+func TestMatchSkipPattern(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		// ** spans segments, including zero of them.
+		{"node_modules/**", "node_modules", true},
+		{"node_modules/**", "node_modules/pkg/index.js", true},
+		{"node_modules/**", "src/node_modules.go", false},
+		{"**/*.tmp", "a/b/c.tmp", true},
+		{"**/*.tmp", "top.tmp", true},
+		{"**/*.tmp", "a/b/c.txt", false},
+		// A slashless pattern matches any single segment at any depth.
+		{"*.min.js", "app.min.js", true},
+		{"*.min.js", "dist/deep/app.min.js", true},
+		{"*.min.js", "app.js", false},
+		{".git", "a/.git/config", true},
+		// * stays within one segment; ? matches exactly one character.
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"?at.txt", "cat.txt", true},
+		{"?at.txt", "chat.txt", false},
+		{"?at.txt", "at.txt", false},
+	}
+	for _, tc := range cases {
+		if got := matchSkipPattern(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchSkipPattern(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParseSkipList(t *testing.T) {
+	if parseSkipList("") != nil {
+		t.Error("empty -skip parsed into a non-nil list")
+	}
+	l := parseSkipList(" node_modules/** , *.min.js ,")
+	if len(l.list()) != 2 {
+		t.Fatalf("parsed %v, want two patterns", l.list())
+	}
+	if !l.match("node_modules/a") || !l.match("x/y.min.js") || l.match("main.go") {
+		t.Error("parsed list does not match as its patterns dictate")
+	}
+}
@@ -0,0 +1,135 @@
+package markov
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A recurring first-run mistake is handing the modes the wrong kind of
+// file: a corpus to generate (which then fails on the missing header),
+// or a model to read (which happily trains a nonsense meta-model on
+// count lines). Both modes sniff the head of each file and redirect the
+// user instead. The heuristics only ever improve an error message or
+// add a guard that -force lifts; they never change how a file is
+// parsed.
+
+// contentKind is sniffContent's best guess at what a file holds.
+type contentKind int
+
+const (
+	contentUnknown     contentKind = iota // too short or too odd to call
+	contentCorpus                         // prose: no model header
+	contentLegacyModel                    // bare header + space-separated suffix/count pairs
+	contentV2Model                        // bare header + tab-separated quoted lines
+	contentJSONModel
+	contentFlatModel
+	contentBinModel
+)
+
+// String names the kind for error messages.
+func (k contentKind) String() string {
+	switch k {
+	case contentCorpus:
+		return "corpus"
+	case contentLegacyModel:
+		return "legacy model"
+	case contentV2Model:
+		return "model"
+	case contentJSONModel:
+		return "JSON model"
+	case contentFlatModel:
+		return "flat model"
+	case contentBinModel:
+		return "binary model"
+	}
+	return "unknown content"
+}
+
+// isModel reports whether the kind is one of the model formats.
+func (k contentKind) isModel() bool {
+	return k == contentLegacyModel || k == contentV2Model || k == contentJSONModel || k == contentFlatModel || k == contentBinModel
+}
+
+// sniffHeadSize is how much of a file the sniffers look at. Every
+// format announces itself within the first couple of lines, so a small
+// head is enough.
+const sniffHeadSize = 4096
+
+// sniffContent classifies the first bytes of a file. It errs toward
+// contentUnknown: a short or ambiguous head (say, a single number on
+// its own) must not trigger a redirect.
+func sniffContent(head []byte) contentKind {
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if len(trimmed) == 0 {
+		return contentUnknown
+	}
+	if len(trimmed) >= len(flatMagic) && bytes.Equal(trimmed[:len(flatMagic)], flatMagic[:]) {
+		return contentFlatModel
+	}
+	if len(trimmed) >= len(binMagic) && bytes.Equal(trimmed[:len(binMagic)], binMagic[:]) {
+		return contentBinModel
+	}
+	if trimmed[0] == '{' {
+		return contentJSONModel
+	}
+	lines := strings.Split(string(head), "\n")
+	if len(lines) > 1 {
+		// The last line may be cut off mid-token by the head cap; only
+		// complete lines are judged.
+		lines = lines[:len(lines)-1]
+	}
+	prefixLen, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || prefixLen < 1 || prefixLen > 64 {
+		// No plausible header line: prose if it holds any words at all.
+		if len(bytes.Fields(head)) > 0 {
+			return contentCorpus
+		}
+		return contentUnknown
+	}
+	// A plausible header. The data lines decide which model format — or
+	// whether this is just prose that happens to open with a number.
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, "\t") {
+			return contentV2Model
+		}
+		if legacyModelLine(line, prefixLen) {
+			return contentLegacyModel
+		}
+		return contentCorpus
+	}
+	return contentUnknown // a bare number: could be either
+}
+
+// legacyModelLine reports whether line has the legacy model shape for
+// the given prefix length: prefix words followed by suffix/count pairs
+// with every count a positive integer.
+func legacyModelLine(line string, prefixLen int) bool {
+	fields := strings.Fields(line)
+	if len(fields) < prefixLen+2 || (len(fields)-prefixLen)%2 != 0 {
+		return false
+	}
+	for i := prefixLen + 1; i < len(fields); i += 2 {
+		if n, err := strconv.Atoi(fields[i]); err != nil || n < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffFile classifies the head of the file at path. Unreadable files
+// are contentUnknown: the real open will report the failure properly.
+func sniffFile(path string) contentKind {
+	f, err := os.Open(path)
+	if err != nil {
+		return contentUnknown
+	}
+	defer f.Close()
+	head := make([]byte, sniffHeadSize)
+	n, _ := f.Read(head)
+	return sniffContent(head[:n])
+}
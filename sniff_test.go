@@ -0,0 +1,84 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffContentFixtures(t *testing.T) {
+	cases := []struct {
+		file string
+		want contentKind
+	}{
+		{"prose.txt", contentCorpus},
+		{"legacy.model", contentLegacyModel},
+		{"v2.model", contentV2Model},
+		// A lone plausible header must stay unknown: redirecting on a
+		// one-number file would misfire on tiny corpora.
+		{"ambiguous.txt", contentUnknown},
+	}
+	for _, tc := range cases {
+		head, err := os.ReadFile(filepath.Join("testdata", "sniff", tc.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sniffContent(head); got != tc.want {
+			t.Errorf("sniffContent(%s) = %v, want %v", tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestSniffContentInline(t *testing.T) {
+	cases := []struct {
+		name string
+		head string
+		want contentKind
+	}{
+		{"empty", "", contentUnknown},
+		{"whitespace only", " \n\t\n", contentUnknown},
+		{"json model", `{"prefix_length": 2}`, contentJSONModel},
+		{"flat model", "MARKFLAT\x01garbage", contentFlatModel},
+		{"prose starting with a number", "2020 was a strange year for all of us\nand the next one was stranger\n", contentCorpus},
+		{"numeric header over prose", "1\nonce upon a time there was a mouse\n", contentCorpus},
+		{"implausible header", "4096\nword 1 word 1\n", contentCorpus},
+		{"v2 option line", "2\n#join-with\t\"\"\n", contentV2Model},
+	}
+	for _, tc := range cases {
+		if got := sniffContent([]byte(tc.head)); got != tc.want {
+			t.Errorf("%s: sniffContent = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateRedirectsCorpusInput(t *testing.T) {
+	corpus := filepath.Join("testdata", "sniff", "prose.txt")
+	code, _, stderr := runCLI("generate", "-model", corpus)
+	if code != exitCorrupt {
+		t.Fatalf("generate on a corpus exited %d, want %d: %s", code, exitCorrupt, stderr)
+	}
+	if !strings.Contains(stderr, "did you mean 'mark read'") {
+		t.Errorf("stderr does not redirect to read mode: %s", stderr)
+	}
+}
+
+func TestReadRefusesModelInput(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.model")
+	model := filepath.Join("testdata", "sniff", "v2.model")
+	code, _, stderr := runCLI("read", "-out", out, model)
+	if code != exitUsage {
+		t.Fatalf("read on a model exited %d, want %d: %s", code, exitUsage, stderr)
+	}
+	if !strings.Contains(stderr, "-force") {
+		t.Errorf("stderr does not mention -force: %s", stderr)
+	}
+	// -force really does train on the file.
+	if code, _, stderr := runCLI("read", "-force", "-out", out, model); code != 0 {
+		t.Fatalf("read -force exited %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("read -force wrote no model: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package markov
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// stats mode summarizes a model's shape: how many prefixes it holds,
+// how much it branches, and where the weight concentrates. Repetitive
+// output usually shows up here as a low average branching factor or a
+// handful of prefixes soaking up most of the counts.
+
+// PrefixCount is one row of the top-prefix report: a prefix and the
+// total weight of its observed continuations.
+type PrefixCount struct {
+	Prefix string  `json:"prefix"`
+	Count  float64 `json:"count"`
+}
+
+// ChainStats summarizes a chain. Counts are float64 because merged or
+// weighted models carry fractional weights.
+type ChainStats struct {
+	Prefixes     int           `json:"prefixes"`     // distinct prefixes
+	Suffixes     float64       `json:"suffixes"`     // total suffix occurrences
+	AvgBranching float64       `json:"avgBranching"` // mean distinct continuations per prefix
+	MaxBranching int           `json:"maxBranching"`
+	DeadEnds     int           `json:"deadEnds"` // prefixes whose every continuation is unknown
+	Top          []PrefixCount `json:"top"`      // most frequent prefixes, heaviest first
+}
+
+// Stats computes the chain's summary, with the top most frequent
+// prefixes by continuation weight (ties broken alphabetically). It
+// works the same on freshly built and loaded chains, since both end up
+// in the one map.
+func (c *Chain) Stats(top int) ChainStats {
+	st := ChainStats{Prefixes: len(c.chain)}
+	distinct := 0
+	rows := make([]PrefixCount, 0, len(c.chain))
+	for key, counts := range c.chain {
+		total := 0.0
+		for _, n := range counts {
+			total += n
+		}
+		st.Suffixes += total
+		distinct += len(counts)
+		if len(counts) > st.MaxBranching {
+			st.MaxBranching = len(counts)
+		}
+		if c.deadEnd(key, counts) {
+			st.DeadEnds++
+		}
+		rows = append(rows, PrefixCount{key, total})
+	}
+	if st.Prefixes > 0 {
+		st.AvgBranching = float64(distinct) / float64(st.Prefixes)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Prefix < rows[j].Prefix
+	})
+	if len(rows) > top {
+		rows = rows[:top]
+	}
+	st.Top = rows
+	return st
+}
+
+// deadEnd reports whether none of key's continuations shift to a
+// prefix the chain knows — a walk reaching key always stops there.
+func (c *Chain) deadEnd(key string, counts map[string]float64) bool {
+	p := Prefix(strings.Split(key, " "))
+	for s := range counts {
+		q := make(Prefix, len(p))
+		copy(q, p)
+		q.Shift(s)
+		if _, ok := c.chain[q.String()]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runStats implements `mark stats`: the chain summary for a model
+// file.
+func runStats(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark stats", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file to summarize")
+	top := fs.Int("top", 10, "how many top prefixes to report")
+	asJSON := fs.Bool("json", false, "emit the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" {
+		return &usageError{msg: "stats mode requires -model"}
+	}
+	if *top < 1 {
+		return &usageError{msg: "-top must be at least 1"}
+	}
+	c, err := loadModelFile(*model)
+	if err != nil {
+		return err
+	}
+	st := c.Stats(*top)
+
+	if *asJSON {
+		b, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return &writeError{path: "stdout", err: err}
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "prefixes:      %d\n", st.Prefixes)
+	fmt.Fprintf(stdout, "suffixes:      %s\n", formatWeight(st.Suffixes))
+	fmt.Fprintf(stdout, "avg branching: %.3f\n", st.AvgBranching)
+	fmt.Fprintf(stdout, "max branching: %d\n", st.MaxBranching)
+	fmt.Fprintf(stdout, "dead ends:     %d\n", st.DeadEnds)
+	tw := tabwriter.NewWriter(stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PREFIX\tCOUNT")
+	for _, r := range st.Top {
+		fmt.Fprintf(tw, "%s\t%s\n", r.Prefix, formatWeight(r.Count))
+	}
+	return tw.Flush()
+}
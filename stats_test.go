@@ -0,0 +1,89 @@
+package markov
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatsHandComputed(t *testing.T) {
+	// Tokens: a b a c b a. With prefix length 1 the chain is
+	//   "" -> {a:1}   a -> {b:1, c:1}   b -> {a:2}   c -> {b:1}
+	// so 4 prefixes, 6 suffix occurrences, 5 distinct continuations,
+	// and every continuation shifts to a known prefix.
+	c := NewChain(1)
+	if err := c.Build(strings.NewReader("a b a c b a")); err != nil {
+		t.Fatal(err)
+	}
+	st := c.Stats(2)
+	if st.Prefixes != 4 {
+		t.Errorf("Prefixes = %d, want 4", st.Prefixes)
+	}
+	if st.Suffixes != 6 {
+		t.Errorf("Suffixes = %v, want 6", st.Suffixes)
+	}
+	if st.AvgBranching != 1.25 {
+		t.Errorf("AvgBranching = %v, want 1.25", st.AvgBranching)
+	}
+	if st.MaxBranching != 2 {
+		t.Errorf("MaxBranching = %d, want 2", st.MaxBranching)
+	}
+	if st.DeadEnds != 0 {
+		t.Errorf("DeadEnds = %d, want 0", st.DeadEnds)
+	}
+	if len(st.Top) != 2 || st.Top[0] != (PrefixCount{"a", 2}) || st.Top[1] != (PrefixCount{"b", 2}) {
+		t.Errorf("Top = %v, want [{a 2} {b 2}]", st.Top)
+	}
+}
+
+func TestStatsCountsDeadEnds(t *testing.T) {
+	// x y z: the prefix "y" only continues to "z", which never starts a
+	// transition — a walk reaching "y" always stops.
+	c := NewChain(1)
+	if err := c.Build(strings.NewReader("x y z")); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Stats(1); st.DeadEnds != 1 {
+		t.Errorf("DeadEnds = %d, want 1", st.DeadEnds)
+	}
+}
+
+func TestStatsCLI(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("a b a c b a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI("stats", "-model", model)
+	if code != 0 {
+		t.Fatalf("stats exited %d: %s", code, stderr)
+	}
+	for _, want := range []string{"prefixes:      4", "dead ends:     0", "PREFIX"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stats output %q is missing %q", stdout, want)
+		}
+	}
+
+	code, stdout, stderr = runCLI("stats", "-model", model, "-json", "-top", "1")
+	if code != 0 {
+		t.Fatalf("stats -json exited %d: %s", code, stderr)
+	}
+	var st ChainStats
+	if err := json.Unmarshal([]byte(stdout), &st); err != nil {
+		t.Fatalf("stats -json emitted bad JSON: %v", err)
+	}
+	if st.Prefixes != 4 || st.Suffixes != 6 || len(st.Top) != 1 {
+		t.Errorf("decoded stats %+v, want 4 prefixes, 6 suffixes, 1 top row", st)
+	}
+
+	if code, _, _ := runCLI("stats"); code != exitUsage {
+		t.Errorf("stats without -model exited %d, want %d", code, exitUsage)
+	}
+}
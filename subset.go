@@ -0,0 +1,100 @@
+package markov
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Subset carves a topical sub-model out of the chain: it keeps every
+// prefix matching re, plus every prefix reachable from one within the
+// given number of hops in the prefix graph (key --suffix--> shifted
+// key), so generation inside the subset does not immediately dead-end.
+// Kept prefixes keep their full suffix distributions; walks leaving
+// the kept region simply stop.
+func (c *Chain) Subset(re *regexp.Regexp, hops int) *Chain {
+	sub := NewChain(c.prefixLen)
+	sub.joinWith = c.joinWith
+	sub.splitOn = c.splitOn
+	sub.splitRe = c.splitRe
+
+	seen := make(map[string]bool)
+	var frontier []string
+	for key := range c.chain {
+		if re.MatchString(key) {
+			seen[key] = true
+			frontier = append(frontier, key)
+		}
+	}
+	for d := 0; d < hops && len(frontier) > 0; d++ {
+		var next []string
+		for _, key := range frontier {
+			p := Prefix(strings.Split(key, " "))
+			for s := range c.chain[key] {
+				q := make(Prefix, len(p))
+				copy(q, p)
+				q.Shift(s)
+				qk := q.String()
+				if _, ok := c.chain[qk]; ok && !seen[qk] {
+					seen[qk] = true
+					next = append(next, qk)
+				}
+			}
+		}
+		frontier = next
+	}
+	for key := range seen {
+		for w, n := range c.chain[key] {
+			sub.addSuffix(key, w, n)
+		}
+	}
+	return sub
+}
+
+// transitionCount sums the number of distinct transitions in the chain.
+func (c *Chain) transitionCount() int {
+	n := 0
+	for _, counts := range c.chain {
+		n += len(counts)
+	}
+	return n
+}
+
+// runSubset extracts the sub-model around -keep-regex and writes it.
+func runSubset(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark subset", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file to read")
+	keepRegex := fs.String("keep-regex", "", "keep prefixes matching this regexp")
+	hops := fs.Int("hops", 2, "also keep prefixes reachable within this many hops of a kept prefix")
+	out := fs.String("o", "", "subset model file to write")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" || *keepRegex == "" || *out == "" {
+		return &usageError{msg: "subset requires -model, -keep-regex, and -o"}
+	}
+	if *hops < 0 {
+		return &usageError{msg: "-hops must not be negative"}
+	}
+	re, err := regexp.Compile(*keepRegex)
+	if err != nil {
+		return &usageError{msg: fmt.Sprintf("bad -keep-regex: %v", err)}
+	}
+	c, err := loadModelFile(*model)
+	if err != nil {
+		return err
+	}
+	sub := c.Subset(re, *hops)
+	if len(sub.chain) == 0 {
+		return fmt.Errorf("no prefix matches %q: %w", *keepRegex, ErrConstraintsUnsatisfied)
+	}
+	if err := atomicWriteFile(*out, sub.writeModel); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "kept %d of %d prefixes (%d of %d transitions)\n",
+		len(sub.chain), len(c.chain), sub.transitionCount(), c.transitionCount())
+	return nil
+}
@@ -0,0 +1,83 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ringChain builds an order-1 ring a -> b -> c -> d -> e -> a, where
+// reachability from any one key is known exactly per hop count.
+func ringChain() *Chain {
+	c := NewChain(1)
+	ring := []string{"a", "b", "c", "d", "e"}
+	for i, w := range ring {
+		c.addSuffix(w, ring[(i+1)%len(ring)], 1)
+	}
+	return c
+}
+
+func TestSubsetKeepsExactlyTheReachableRegion(t *testing.T) {
+	c := ringChain()
+	sub := c.Subset(regexp.MustCompile(`^a$`), 2)
+	for _, want := range []string{"a", "b", "c"} {
+		if sub.chain[want] == nil {
+			t.Errorf("prefix %q (within 2 hops of a) was dropped", want)
+		}
+	}
+	for _, drop := range []string{"d", "e"} {
+		if sub.chain[drop] != nil {
+			t.Errorf("prefix %q (3+ hops from a) was kept", drop)
+		}
+	}
+	// Kept prefixes keep their full suffix distribution.
+	if sub.chain["c"]["d"] != 1 {
+		t.Errorf("kept prefix c lost its suffix map: %v", sub.chain["c"])
+	}
+}
+
+func TestSubsetZeroHopsKeepsOnlyMatches(t *testing.T) {
+	sub := ringChain().Subset(regexp.MustCompile(`^[ab]$`), 0)
+	if len(sub.chain) != 2 || sub.chain["a"] == nil || sub.chain["b"] == nil {
+		t.Errorf("0-hop subset kept %v, want exactly a and b", len(sub.chain))
+	}
+}
+
+func TestSubsetCLI(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("the ship sails the cold sea while the dog sleeps by the fire ", 10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	out := filepath.Join(dir, "nautical.model")
+	code, _, stderr := runCLI("subset", "-model", model, "-keep-regex", `^(sea|ship|sails)$`, "-hops", "1", "-o", out)
+	if code != 0 {
+		t.Fatalf("subset exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "prefixes") || !strings.Contains(stderr, "transitions") {
+		t.Errorf("report %q does not give before/after sizes", stderr)
+	}
+	sub, err := loadTextChain(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.chain["ship"] == nil {
+		t.Error("matching prefix missing from the written subset")
+	}
+	if sub.chain["dog"] != nil {
+		t.Error("unrelated prefix survived the subset")
+	}
+
+	if code, _, _ := runCLI("subset", "-model", model, "-keep-regex", `^zzz$`, "-o", out); code != exitConstraints {
+		t.Errorf("subset with no matches exited %d, want %d", code, exitConstraints)
+	}
+	if code, _, _ := runCLI("subset", "-model", model, "-keep-regex", `(`, "-o", out); code != exitUsage {
+		t.Errorf("subset with a bad regex exited %d, want %d", code, exitUsage)
+	}
+}
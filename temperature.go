@@ -0,0 +1,86 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sampling temperature reshapes a suffix distribution before the draw:
+// each weight w becomes w^(1/T), so T < 1 sharpens toward the most
+// common continuation and T > 1 flattens toward uniform. T = 1 leaves
+// the distribution untouched.
+
+// weightedChoiceT is weightedChoice with a temperature applied. It
+// visits suffixes in sorted order and draws through pickWeighted, so at
+// temperature 1 it makes exactly the draws weightedChoice makes.
+func weightedChoiceT(rng *rand.Rand, counts map[string]float64, temp float64) string {
+	if temp == 1 {
+		return weightedChoice(rng, counts)
+	}
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	inv := 1 / temp
+	reshaped := make([]float64, len(words))
+	for i, w := range words {
+		reshaped[i] = math.Pow(counts[w], inv)
+	}
+	return words[pickWeighted(rng, len(reshaped), func(i int) float64 { return reshaped[i] })]
+}
+
+// topKCounts restricts a distribution to its k heaviest suffixes.
+// Every suffix tied with the k-th heaviest stays in, so the cutoff
+// never breaks a tie by map order; a distribution with at most k
+// suffixes comes back as-is.
+func topKCounts(counts map[string]float64, k int) map[string]float64 {
+	if k <= 0 || len(counts) <= k {
+		return counts
+	}
+	weights := make([]float64, 0, len(counts))
+	for _, n := range counts {
+		weights = append(weights, n)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(weights)))
+	threshold := weights[k-1]
+	kept := make(map[string]float64, k)
+	for w, n := range counts {
+		if n >= threshold {
+			kept[w] = n
+		}
+	}
+	return kept
+}
+
+// linearSchedule interpolates the temperature linearly from "from" at
+// word 0 to "to" at the final requested word. Other shapes (cosine,
+// step) can slot in behind the same TemperatureAt hook.
+func linearSchedule(from, to float64) func(step, total int) float64 {
+	return func(step, total int) float64 {
+		if total <= 1 {
+			return from
+		}
+		f := float64(step) / float64(total-1)
+		return from + (to-from)*f
+	}
+}
+
+// parseTemperatureSchedule parses the -temperature-schedule value,
+// "start:end" with both positive, into a linear schedule.
+func parseTemperatureSchedule(s string) (func(step, total int) float64, error) {
+	first, second, found := strings.Cut(s, ":")
+	if !found {
+		return nil, fmt.Errorf("temperature schedule %q must be start:end", s)
+	}
+	from, err1 := strconv.ParseFloat(first, 64)
+	to, err2 := strconv.ParseFloat(second, 64)
+	if err1 != nil || err2 != nil || from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("temperature schedule %q must hold two positive numbers", s)
+	}
+	return linearSchedule(from, to), nil
+}
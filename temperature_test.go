@@ -0,0 +1,159 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestModel writes a small order-1 model file for CLI tests.
+func writeTestModel(t *testing.T) string {
+	t.Helper()
+	c := NewChain(1)
+	c.Build(strings.NewReader(strings.Repeat("tick tock ", 20)))
+	path := filepath.Join(t.TempDir(), "m.model")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeModel(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLinearScheduleEndpointsAndMidpoint(t *testing.T) {
+	sched := linearSchedule(0.8, 1.5)
+	const total = 101
+	cases := []struct {
+		step int
+		want float64
+	}{
+		{0, 0.8},
+		{total - 1, 1.5},
+		{(total - 1) / 2, 1.15},
+	}
+	for _, tc := range cases {
+		if got := sched(tc.step, total); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("sched(%d, %d) = %g, want %g", tc.step, total, got, tc.want)
+		}
+	}
+	// A single-word run has no span to interpolate over.
+	if got := sched(0, 1); got != 0.8 {
+		t.Errorf("sched(0, 1) = %g, want the start value", got)
+	}
+}
+
+func TestConstantScheduleMatchesPlainTemperature(t *testing.T) {
+	a := benchChain(t)
+	b := benchChain(t)
+	for seed := int64(1); seed <= 5; seed++ {
+		rand.Seed(seed)
+		plain := a.GenerateWithOptions(GenerateOptions{Words: 200, Temperature: 0.5})
+		rand.Seed(seed)
+		sched := b.GenerateWithOptions(GenerateOptions{
+			Words:         200,
+			TemperatureAt: func(step, total int) float64 { return 0.5 },
+		})
+		if plain.Text != sched.Text {
+			t.Fatalf("seed %d: constant schedule diverges from plain temperature\nsched: %q\nplain: %q", seed, sched.Text, plain.Text)
+		}
+	}
+}
+
+func TestLowTemperatureSharpensDraws(t *testing.T) {
+	rand.Seed(1)
+	counts := map[string]float64{"heavy": 3, "light": 1}
+	heavy := 0
+	for i := 0; i < 2000; i++ {
+		if weightedChoiceT(nil, counts, 0.1) == "heavy" {
+			heavy++
+		}
+	}
+	// At T=0.1 the 3:1 split becomes 3^10:1, i.e. essentially always heavy.
+	if heavy < 1990 {
+		t.Errorf("heavy drawn %d/2000 times at T=0.1, want nearly always", heavy)
+	}
+}
+
+func TestTopKCountsKeepsBoundaryTies(t *testing.T) {
+	counts := map[string]float64{"a": 3, "b": 2, "c": 2, "d": 1}
+	kept := topKCounts(counts, 2)
+	if len(kept) != 3 {
+		t.Fatalf("topKCounts kept %v, want a, b, and the tied c", kept)
+	}
+	for _, w := range []string{"a", "b", "c"} {
+		if _, ok := kept[w]; !ok {
+			t.Errorf("topKCounts dropped %q", w)
+		}
+	}
+}
+
+func TestTopKCountsLeavesSmallDistributionsAlone(t *testing.T) {
+	counts := map[string]float64{"a": 1, "b": 1}
+	if kept := topKCounts(counts, 5); len(kept) != 2 {
+		t.Errorf("topKCounts trimmed a distribution smaller than k: %v", kept)
+	}
+}
+
+func TestTopKOneAlwaysPicksHeaviest(t *testing.T) {
+	// a continues to b twice and c once, so top-k 1 makes every draw
+	// after an "a" deterministic whatever the seed.
+	c := NewChain(1)
+	c.Build(strings.NewReader("a b a c a b"))
+	for seed := int64(1); seed <= 5; seed++ {
+		c.SetRandom(rand.NewSource(seed))
+		res := c.GenerateWithOptions(GenerateOptions{Words: 6, TopK: 1})
+		if res.Text != "a b a b a b" {
+			t.Fatalf("seed %d: top-k 1 generated %q, want %q", seed, res.Text, "a b a b a b")
+		}
+	}
+}
+
+func TestGenerateTemperatureAndTopKFlags(t *testing.T) {
+	model := writeTestModel(t)
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "20", "-temperature", "0.5", "-top-k", "1")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output with -temperature and -top-k")
+	}
+	if code, _, _ := runCLI("generate", "-model", model, "-temperature", "0"); code != exitUsage {
+		t.Errorf("-temperature 0 exited %d, want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("generate", "-model", model, "-top-k", "-1"); code != exitUsage {
+		t.Errorf("-top-k -1 exited %d, want %d", code, exitUsage)
+	}
+}
+
+func TestParseTemperatureScheduleRejectsMalformed(t *testing.T) {
+	for _, s := range []string{"1.5", "x:y", "0:1", "1:-2", ":", ""} {
+		if _, err := parseTemperatureSchedule(s); err == nil {
+			t.Errorf("parseTemperatureSchedule(%q) accepted malformed input", s)
+		}
+	}
+	if _, err := parseTemperatureSchedule("0.8:1.5"); err != nil {
+		t.Errorf("parseTemperatureSchedule(0.8:1.5): %v", err)
+	}
+}
+
+func TestGenerateTemperatureScheduleFlag(t *testing.T) {
+	model := writeTestModel(t)
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "20", "-temperature-schedule", "0.8:1.5")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if len(strings.Fields(stdout)) == 0 {
+		t.Error("no output with a temperature schedule")
+	}
+	if code, _, _ := runCLI("generate", "-model", model, "-temperature-schedule", "nope"); code != exitUsage {
+		t.Errorf("malformed schedule exited %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,108 @@
+package markov
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scannerOf returns a scanner over model text with the prefix length
+// header already consumed, the state BuildFromRead expects.
+func scannerOf(model string) *bufio.Scanner {
+	sc := bufio.NewScanner(strings.NewReader(model))
+	sc.Scan() // skip the header line
+	return sc
+}
+
+func TestSlashSeparatedCorpusRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "paths.txt")
+	if err := os.WriteFile(corpus, []byte("usr/local/bin\nusr/local/share\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "paths.model")
+	code, _, stderr := runCLI("read", "-prefix", "1",
+		"-split-on", "/", "-join-with", "/", "-out", model, corpus)
+	if code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+
+	raw, err := os.ReadFile(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, option := range []string{"#join-with\t\"/\"", "#split-on\t\"/\""} {
+		if !strings.Contains(string(raw), option) {
+			t.Errorf("model header is missing option line %q:\n%s", option, raw)
+		}
+	}
+
+	// Generate mode must pick the joiner up from the model header: the
+	// output is path components joined with '/', never spaces.
+	code, stdout, stderr := runCLI("generate", "-model", model, "-words", "5")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	text := strings.TrimSpace(stdout)
+	if text == "" || !strings.Contains(text, "/") {
+		t.Errorf("output %q is not '/'-joined", text)
+	}
+	if strings.Contains(text, " ") {
+		t.Errorf("output %q contains a space despite -join-with /", text)
+	}
+	for _, component := range strings.Split(text, "/") {
+		switch component {
+		case "usr", "local", "bin", "share":
+		default:
+			t.Errorf("output component %q is not a corpus token", component)
+		}
+	}
+}
+
+func TestJoinWithNewlineSurvivesModelHeader(t *testing.T) {
+	c := NewChain(1)
+	c.SetJoiner("\n")
+	c.addSuffix("\"\"", "a", 1)
+	var b strings.Builder
+	if err := c.writeModel(&b); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewChain(1)
+	if err := loaded.BuildFromRead(scannerOf(b.String()), 1); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.joinWith != "\n" {
+		t.Errorf("joinWith after reload = %q, want newline", loaded.joinWith)
+	}
+}
+
+func TestSplitOnSurvivesHugeSingleLine(t *testing.T) {
+	// A single-line corpus is legal -split-on input, but the scanner's
+	// default 64KB line cap used to end the scan before the first token:
+	// a 120KB line trained nothing and the build still reported success.
+	c := NewChain(1)
+	if err := c.SetTokenizer(","); err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("tick,tock,", 12_000) // one 120KB line
+	if err := c.Build(strings.NewReader(line)); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.chain["tick"]["tock"]; got != 12_000 {
+		t.Errorf("tick→tock seen %v times, want 12000", got)
+	}
+}
+
+func TestSetTokenizerRejectsEmptyMatch(t *testing.T) {
+	c := NewChain(1)
+	if err := c.SetTokenizer("a*"); err == nil {
+		t.Error("SetTokenizer accepted a pattern matching the empty string")
+	} else if !strings.Contains(err.Error(), "empty string") {
+		t.Errorf("error %q does not explain the empty-string problem", err)
+	}
+	if err := c.SetTokenizer("("); err == nil {
+		t.Error("SetTokenizer accepted an invalid regexp")
+	}
+}
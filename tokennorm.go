@@ -0,0 +1,98 @@
+package markov
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Whitespace splitting leaves "Hello," "hello" and "Hello" as three
+// distinct tokens, which fragments the chain badly on small corpora.
+// The normalization options here run between the tokenizer and the
+// chain: lowercase folding merges case variants, and punctuation
+// splitting peels leading and trailing punctuation into tokens of
+// their own so "end." contributes to the same entry as "end". Both are
+// off by default and both travel in the model header, so generate mode
+// renders text the way the model was built.
+
+// SetLowercase makes Build fold every token to lower case, so "The"
+// and "the" share one suffix distribution.
+func (c *Chain) SetLowercase(on bool) { c.lowercase = on }
+
+// SetSplitPunct makes Build split leading and trailing punctuation off
+// each token, one token per punctuation character, and makes generated
+// text re-attach punctuation-only tokens without a preceding joiner.
+func (c *Chain) SetSplitPunct(on bool) { c.splitPunct = on }
+
+// normalizeToken applies the enabled normalizations to one raw token,
+// handing fn each resulting token in order.
+func (c *Chain) normalizeToken(tok string, fn func(token string)) {
+	if c.lowercase {
+		tok = strings.ToLower(tok)
+	}
+	if c.charLevel {
+		eachRune(tok, fn)
+		return
+	}
+	if !c.splitPunct {
+		fn(tok)
+		return
+	}
+	for _, piece := range splitPunctToken(tok) {
+		fn(piece)
+	}
+}
+
+// splitPunctToken peels punctuation off both ends of a token:
+// "(end)." becomes "(", "end", ")", ".". Interior punctuation —
+// hyphens, apostrophes — stays put, so "covid-19" and "don't" survive
+// as single tokens.
+func splitPunctToken(tok string) []string {
+	runes := []rune(tok)
+	start, end := 0, len(runes)
+	for start < end && unicode.IsPunct(runes[start]) {
+		start++
+	}
+	for end > start && unicode.IsPunct(runes[end-1]) {
+		end--
+	}
+	if start == 0 && end == len(runes) {
+		return []string{tok}
+	}
+	pieces := make([]string, 0, start+1+len(runes)-end)
+	for _, r := range runes[:start] {
+		pieces = append(pieces, string(r))
+	}
+	if start < end {
+		pieces = append(pieces, string(runes[start:end]))
+	}
+	for _, r := range runes[end:] {
+		pieces = append(pieces, string(r))
+	}
+	return pieces
+}
+
+// joinTokens renders generated tokens as text. Under punctuation
+// splitting, punctuation-only tokens re-attach to the preceding word
+// instead of floating after a joiner.
+func (c *Chain) joinTokens(words []string) string {
+	if !c.splitPunct {
+		return strings.Join(words, c.joinWith)
+	}
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 && !punctOnly(w) {
+			b.WriteString(c.joinWith)
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// joinerBefore is the joiner placed before one generated token: the
+// chain's joiner, or nothing for a re-attaching punctuation token.
+func (c *Chain) joinerBefore(word string) string {
+	if c.splitPunct && punctOnly(word) {
+		return ""
+	}
+	return c.joinWith
+}
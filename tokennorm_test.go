@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLowercaseMergesCaseVariants(t *testing.T) {
+	c := NewChain(1)
+	c.SetLowercase(true)
+	c.Build(strings.NewReader("The cat saw the dog and the bird"))
+	if _, ok := c.chain["The"]; ok {
+		t.Error(`"The" survived as its own key with lowercasing on`)
+	}
+	suffixes := c.chain["the"]
+	for _, want := range []string{"cat", "dog", "bird"} {
+		if suffixes[want] != 1 {
+			t.Errorf(`"the" suffixes = %v, want cat, dog, and bird sharing one list`, suffixes)
+		}
+	}
+}
+
+func TestSplitPunctToken(t *testing.T) {
+	for tok, want := range map[string][]string{
+		"end.":      {"end", "."},
+		"(end).":    {"(", "end", ")", "."},
+		"covid-19":  {"covid-19"},
+		"don't":     {"don't"},
+		"--":        {"-", "-"},
+		"plain":     {"plain"},
+		`"quoted,"`: {`"`, "quoted", ",", `"`},
+	} {
+		if got := splitPunctToken(tok); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitPunctToken(%q) = %v, want %v", tok, got, want)
+		}
+	}
+}
+
+func TestSplitPunctReattachesWithoutSpace(t *testing.T) {
+	// The corpus tokenizes into the cycle one , two . — every walk is the
+	// same, so the rendered output is exact.
+	c := NewChain(1)
+	c.SetSplitPunct(true)
+	c.Build(strings.NewReader("one, two. one, two."))
+	if c.chain["one"][","] != 2 {
+		t.Fatalf(`chain %v did not split "one," into "one" and ","`, c.chain)
+	}
+	got := c.Generate(4)
+	if got != "one, two. one, two" {
+		t.Errorf("generated %q, want the punctuation re-attached without spaces", got)
+	}
+}
+
+func TestNormalizationSurvivesReload(t *testing.T) {
+	c := NewChain(1)
+	c.SetLowercase(true)
+	c.SetSplitPunct(true)
+	c.Build(strings.NewReader("One, two. One, two."))
+	loaded := reload(t, c, c.writeModel, readModelStream)
+	if !loaded.lowercase || !loaded.splitPunct {
+		t.Errorf("reload dropped normalization options: lowercase=%v splitPunct=%v", loaded.lowercase, loaded.splitPunct)
+	}
+	if !c.Equal(loaded) {
+		t.Error("normalized chain lost data in the text round trip")
+	}
+}
+
+func TestNormalizationFlags(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("One, two. One, two. one, two."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.txt")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-lowercase", "-split-punct", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("generate", "-model", model, "-seed", "3", "-words", "20")
+	if code != 0 {
+		t.Fatalf("generate exited %d: %s", code, stderr)
+	}
+	if stdout != strings.ToLower(stdout) {
+		t.Errorf("output %q is not lowercased", stdout)
+	}
+	for _, bad := range []string{" ,", " ."} {
+		if strings.Contains(stdout, bad) {
+			t.Errorf("output %q has a space before punctuation", stdout)
+		}
+	}
+	if !strings.ContainsAny(stdout, ",.") {
+		t.Errorf("output %q lost its punctuation entirely", stdout)
+	}
+}
@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildAccumulatesOverLoadedModel(t *testing.T) {
+	textA := "the sun rises and the sun sets"
+	textB := "the moon rises and the moon sets"
+
+	saved := NewChain(1)
+	if err := saved.Build(strings.NewReader(textA)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := saved.writeModel(&buf); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := readModelStream(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updated.Build(strings.NewReader(textB)); err != nil {
+		t.Fatal(err)
+	}
+
+	// One pass over both texts, with the per-text prefix reset the two
+	// Build calls above also get.
+	fresh := NewChain(1)
+	for _, text := range []string{textA, textB} {
+		if err := fresh.Build(strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !updated.Equal(fresh) {
+		t.Error("updating a loaded model differs from training on both texts at once")
+	}
+}
+
+func TestUpdateFlag(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.txt", strings.Repeat("tick tock ", 10))
+	b := write("b.txt", strings.Repeat("ding dong ", 10))
+
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", model, a); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCLI("read", "-update", "-out", model, b); code != 0 {
+		t.Fatalf("read -update exited %d: %s", code, stderr)
+	}
+
+	both := filepath.Join(dir, "both.model")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-out", both, a, b); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	updated, err := loadModelFile(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneShot, err := loadModelFile(both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.Equal(oneShot) {
+		t.Error("updated model differs from the one trained on both corpora at once")
+	}
+}
+
+func TestUpdateRefusesFlatModel(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte("a b a c a b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.flat")
+	if code, _, stderr := runCLI("read", "-prefix", "1", "-format", "flat", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	code, _, stderr := runCLI("read", "-update", "-out", model, corpus)
+	if code != exitUsage || !strings.Contains(stderr, "flat") {
+		t.Errorf("updating a flat model exited %d (%s), want a usage error naming the flat format", code, stderr)
+	}
+}
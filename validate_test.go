@@ -0,0 +1,77 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// doctoredModel returns a model whose header claims prefix length 2
+// but whose keys are single words — the wrong-arity corruption an
+// edited file or buggy exporter produces.
+func doctoredModel(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "doctored.model")
+	model := "2\n" +
+		"alpha\t\"beta\"\t1\n" +
+		"beta\t\"gamma\"\t2\n"
+	if err := os.WriteFile(path, []byte(model), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateKeysCatchesWrongArity(t *testing.T) {
+	path := doctoredModel(t)
+	for _, mode := range []string{"sample", "full"} {
+		code, _, stderr := runCLI("generate", "-model", path, "-validate-keys", mode)
+		if code != exitCorrupt {
+			t.Errorf("-validate-keys %s exited %d, want %d", mode, code, exitCorrupt)
+		}
+		if !strings.Contains(stderr, "expected 2") {
+			t.Errorf("-validate-keys %s diagnostic %q does not name the expected arity", mode, stderr)
+		}
+	}
+}
+
+func TestGenerateDetectsMissedStartLookups(t *testing.T) {
+	// Without validation the load succeeds, but every lookup misses;
+	// that must surface as a corrupt-model diagnostic, not silence.
+	code, stdout, stderr := runCLI("generate", "-model", doctoredModel(t))
+	if code != exitCorrupt {
+		t.Fatalf("generate exited %d, want %d (stdout %q)", code, exitCorrupt, stdout)
+	}
+	if !strings.Contains(stderr, "validate-keys") {
+		t.Errorf("diagnostic %q does not point at -validate-keys", stderr)
+	}
+}
+
+func TestValidateKeysAcceptsHealthyModel(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(corpus, []byte(strings.Repeat("a b c ", 10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "m.model")
+	if code, _, stderr := runCLI("read", "-prefix", "2", "-out", model, corpus); code != 0 {
+		t.Fatalf("read exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCLI("generate", "-model", model, "-validate-keys", "full"); code != 0 {
+		t.Errorf("healthy model failed validation: exit %d, %s", code, stderr)
+	}
+}
+
+func TestSuspectCorruptNotSetOnNormalDeadEnd(t *testing.T) {
+	// A walk that produces words and then dead-ends is normal.
+	c := NewChain(1)
+	c.addSuffix("\"\"", "a", 1)
+	c.addSuffix("a", "b", 1)
+	res := c.GenerateWithOptions(GenerateOptions{Words: 10})
+	if res.SuspectCorrupt {
+		t.Error("SuspectCorrupt set for a normal dead-end walk")
+	}
+	if res.Words != 2 {
+		t.Errorf("Words = %d, want 2", res.Words)
+	}
+}
@@ -0,0 +1,84 @@
+package markov
+
+import (
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// After four model formats, the paranoid question is whether the
+// in-memory chain and its disk form still agree. Fingerprint answers it
+// without materializing a second chain: it hashes the canonical sorted
+// serialization (exactly what writeModel emits), so two chains are
+// Equal iff their fingerprints match. verify mode runs the check
+// end-to-end: load, re-serialize through the canonical reader, compare.
+
+// Fingerprint returns the SHA-256 of the chain's canonical
+// serialization. The canonical form is sorted and covers every
+// registered model section, so it is insertion-order independent and
+// changes whenever any count, key, or recorded option changes.
+func (c *Chain) Fingerprint() [32]byte {
+	h := sha256.New()
+	c.writeModel(h) // writes to a hash cannot fail
+	var fp [32]byte
+	copy(fp[:], h.Sum(nil))
+	return fp
+}
+
+// roundTripFingerprint serializes the chain canonically, reloads it,
+// and returns the reloaded chain's fingerprint. A mismatch with
+// c.Fingerprint() means the writer and reader disagree about some
+// section of the model.
+func (c *Chain) roundTripFingerprint() ([32]byte, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(c.writeModel(pw))
+	}()
+	reloaded, err := readModelStream(pr)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return reloaded.Fingerprint(), nil
+}
+
+// runVerify loads the -model, checks key arity, and re-serializes it
+// through the canonical reader to prove the representation has not
+// drifted. -fingerprint additionally prints the canonical fingerprint.
+func runVerify(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("mark verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("model", "", "model file to verify")
+	fingerprint := fs.Bool("fingerprint", false, "print the canonical fingerprint")
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *model == "" {
+		return &usageError{msg: "verify mode requires -model"}
+	}
+	c, err := loadModelFile(*model)
+	if err != nil {
+		return err
+	}
+	if err := c.validateKeyArity(true); err != nil {
+		var ce *corruptError
+		if errors.As(err, &ce) {
+			ce.path = *model
+		}
+		return err
+	}
+	fp := c.Fingerprint()
+	reloaded, err := c.roundTripFingerprint()
+	if err != nil {
+		return &corruptError{path: *model, msg: fmt.Sprintf("canonical round trip failed: %v", err)}
+	}
+	if reloaded != fp {
+		return &corruptError{path: *model, msg: fmt.Sprintf("representation drift: fingerprint %x reloads as %x", fp, reloaded)}
+	}
+	if *fingerprint {
+		fmt.Fprintf(stdout, "%x\n", fp)
+	}
+	fmt.Fprintf(stderr, "verified %s: %d prefixes, round trip ok\n", *model, len(c.chain))
+	return nil
+}
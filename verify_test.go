@@ -0,0 +1,79 @@
+package markov
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintInsertionOrderIndependent(t *testing.T) {
+	a := NewChain(1)
+	a.addSuffix("the", "cat", 2)
+	a.addSuffix("the", "dog", 1)
+	a.addSuffix("a", "mouse", 3)
+	b := NewChain(1)
+	b.addSuffix("a", "mouse", 1)
+	b.addSuffix("the", "dog", 1)
+	b.addSuffix("the", "cat", 2)
+	b.addSuffix("a", "mouse", 2)
+	if !a.Equal(b) {
+		t.Fatal("fixture chains are not equal")
+	}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("equal chains built in different orders have different fingerprints")
+	}
+}
+
+func TestFingerprintSensitiveToSingleCount(t *testing.T) {
+	a := NewChain(1)
+	a.addSuffix("the", "cat", 2)
+	b := NewChain(1)
+	b.addSuffix("the", "cat", 3)
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("a one-count difference did not change the fingerprint")
+	}
+	// Options are part of the canonical form too.
+	c := NewChain(1)
+	c.addSuffix("the", "cat", 2)
+	c.SetJoiner("")
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Error("a joiner difference did not change the fingerprint")
+	}
+}
+
+func TestFingerprintSurvivesRoundTrip(t *testing.T) {
+	c := maximalChain(t)
+	fp, err := c.roundTripFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp != c.Fingerprint() {
+		t.Error("canonical round trip changed the fingerprint")
+	}
+}
+
+func TestVerifyMode(t *testing.T) {
+	model := writeTestModel(t)
+	code, stdout, stderr := runCLI("verify", "-model", model)
+	if code != 0 {
+		t.Fatalf("verify exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "round trip ok") {
+		t.Errorf("verify report missing: %s", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("verify without -fingerprint wrote %q to stdout", stdout)
+	}
+
+	code, stdout, stderr = runCLI("verify", "-fingerprint", "-model", model)
+	if code != 0 {
+		t.Fatalf("verify -fingerprint exited %d: %s", code, stderr)
+	}
+	if len(strings.TrimSpace(stdout)) != 64 {
+		t.Errorf("fingerprint output %q is not 64 hex characters", stdout)
+	}
+
+	if code, _, _ := runCLI("verify", "-model", filepath.Join(t.TempDir(), "nope.model")); code != exitNotFound {
+		t.Errorf("verify on a missing model exited %d, want %d", code, exitNotFound)
+	}
+}
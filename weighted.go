@@ -0,0 +1,114 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// seedRand seeds the global generator every sampler draws from. A
+// non-zero seed makes runs reproducible — same model, same seed, same
+// bytes out — while zero keeps the traditional clock seeding.
+func seedRand(seed int64) {
+	if seed != 0 {
+		rand.Seed(seed)
+		return
+	}
+	rand.Seed(time.Now().UnixNano())
+}
+
+// All weighted sampling routes through pickWeighted so bias has one
+// place to creep in and one place to be tested. Two failure modes it
+// exists to rule out: integer selection over a truncated total (the
+// rand.Intn(int(total)) pattern under-weights the tail once counts
+// stop being exactly representable), and naive float cumulative sums
+// drifting when millions of counts are accumulated.
+
+// maxExactWeight is the largest weight the integer path accepts: above
+// 2^53 a float64 can no longer hold every integer exactly.
+const maxExactWeight = 1 << 53
+
+// kahanSum totals the weights with compensated summation, bounding the
+// error independently of n.
+func kahanSum(n int, weight func(int) float64) float64 {
+	sum, comp := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		y := weight(i) - comp
+		t := sum + y
+		comp = (t - sum) - y
+		sum = t
+	}
+	return sum
+}
+
+// randInt63n and randFloat64 draw from the given generator, or from
+// the package-global one when rng is nil. The global generator is what
+// Main seeds; chains given their own source via SetRandom never touch
+// it, so two chains in one process cannot perturb each other's draws.
+func randInt63n(rng *rand.Rand, n int64) int64 {
+	if rng != nil {
+		return rng.Int63n(n)
+	}
+	return rand.Int63n(n)
+}
+
+func randFloat64(rng *rand.Rand) float64 {
+	if rng != nil {
+		return rng.Float64()
+	}
+	return rand.Float64()
+}
+
+func randIntn(rng *rand.Rand, n int) int {
+	if rng != nil {
+		return rng.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// pickWeighted returns an index in [0, n), chosen with probability
+// proportional to weight(i). Callers fix the visiting order (sorted,
+// for reproducible seeded draws). When every weight is an integer the
+// selection is exact: prefix sums and rand.Int63n over the exact
+// total. Otherwise the total is compensated and the draw is a single
+// rand.Float64. Returns -1 when n is 0.
+func pickWeighted(rng *rand.Rand, n int, weight func(int) float64) int {
+	if n == 0 {
+		return -1
+	}
+	integral := true
+	for i := 0; i < n; i++ {
+		w := weight(i)
+		if w != math.Trunc(w) || w < 0 || w >= maxExactWeight {
+			integral = false
+			break
+		}
+	}
+	if integral {
+		var total int64
+		for i := 0; i < n; i++ {
+			total += int64(weight(i))
+		}
+		if total > 0 {
+			r := randInt63n(rng, total)
+			var c int64
+			for i := 0; i < n; i++ {
+				c += int64(weight(i))
+				if c > r {
+					return i
+				}
+			}
+		}
+		return n - 1
+	}
+	total := kahanSum(n, weight)
+	r := randFloat64(rng) * total
+	c := 0.0
+	for i := 0; i < n; i++ {
+		c += weight(i)
+		if c > r {
+			return i
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,120 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// chiSquare returns the chi-square statistic of observed draw counts
+// against the expected distribution implied by the weights.
+func chiSquare(observed []int, weights []float64, draws int) float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	stat := 0.0
+	for i, o := range observed {
+		expected := float64(draws) * weights[i] / total
+		d := float64(o) - expected
+		stat += d * d / expected
+	}
+	return stat
+}
+
+func drawCounts(t *testing.T, weights []float64, draws int, seed int64) []int {
+	t.Helper()
+	rand.Seed(seed)
+	observed := make([]int, len(weights))
+	for i := 0; i < draws; i++ {
+		observed[pickWeighted(nil, len(weights), func(i int) float64 { return weights[i] })]++
+	}
+	return observed
+}
+
+func TestPickWeightedIntegerDistribution(t *testing.T) {
+	// df = 3; 16.27 is the p = 0.001 critical value, generous enough
+	// that a fixed seed never trips it unless the sampler is biased.
+	weights := []float64{1, 2, 3, 4}
+	observed := drawCounts(t, weights, 100000, 1)
+	if stat := chiSquare(observed, weights, 100000); stat > 16.27 {
+		t.Errorf("integer path chi-square = %.2f over threshold 16.27 (observed %v)", stat, observed)
+	}
+}
+
+func TestPickWeightedFloatDistribution(t *testing.T) {
+	weights := []float64{0.5, 1.25, 2.75, 3.5}
+	observed := drawCounts(t, weights, 100000, 2)
+	if stat := chiSquare(observed, weights, 100000); stat > 16.27 {
+		t.Errorf("float path chi-square = %.2f over threshold 16.27 (observed %v)", stat, observed)
+	}
+}
+
+func TestPickWeightedExtremeSkew(t *testing.T) {
+	// One weight of 10^9 against three of 1: the expected count for the
+	// small weights over 200k draws is 0.0006, so every draw should land
+	// on the heavy suffix. An integer sampler that truncated the total
+	// or overflowed would leak draws to the tail.
+	weights := []float64{1e9, 1, 1, 1}
+	observed := drawCounts(t, weights, 200000, 3)
+	if observed[0] != 200000 {
+		t.Errorf("extreme skew draws = %v, want all 200000 on the 1e9 weight", observed)
+	}
+}
+
+func TestPickWeightedManyTinyWeights(t *testing.T) {
+	// 1000 equal weights of 1e-9 each: a naive running total loses
+	// precision long before Kahan does. df = 999, mean 999, sd ~44.7;
+	// 1200 is well past p = 0.001.
+	weights := make([]float64, 1000)
+	for i := range weights {
+		weights[i] = 1e-9
+	}
+	observed := drawCounts(t, weights, 100000, 4)
+	if stat := chiSquare(observed, weights, 100000); stat > 1200 {
+		t.Errorf("tiny-weight chi-square = %.2f over threshold 1200", stat)
+	}
+}
+
+func TestKahanSumCompensates(t *testing.T) {
+	// 1e16 is past 2^53, so naively adding 1 sixteen times is absorbed
+	// entirely; compensation carries it. 1e16+16 is exactly
+	// representable (the spacing at that magnitude is 2).
+	weights := make([]float64, 17)
+	weights[0] = 1e16
+	for i := 1; i < len(weights); i++ {
+		weights[i] = 1
+	}
+	naive := 0.0
+	for _, w := range weights {
+		naive += w
+	}
+	if naive != 1e16 {
+		t.Fatalf("naive sum = %v; the fixture no longer exercises absorption", naive)
+	}
+	got := kahanSum(len(weights), func(i int) float64 { return weights[i] })
+	if got != 1e16+16 {
+		t.Errorf("kahanSum = %v, want %v", got, 1e16+16.0)
+	}
+}
+
+func TestPickWeightedEdgeCases(t *testing.T) {
+	if got := pickWeighted(nil, 0, nil); got != -1 {
+		t.Errorf("pickWeighted over nothing = %d, want -1", got)
+	}
+	one := []float64{7}
+	if got := pickWeighted(nil, 1, func(i int) float64 { return one[i] }); got != 0 {
+		t.Errorf("pickWeighted over one weight = %d, want 0", got)
+	}
+	// Weights past 2^53 cannot use exact integer arithmetic and must
+	// fall back to the float path rather than silently truncating.
+	big := []float64{math.Ldexp(1, 60), math.Ldexp(1, 60)}
+	rand.Seed(5)
+	counts := [2]int{}
+	for i := 0; i < 1000; i++ {
+		counts[pickWeighted(nil, 2, func(i int) float64 { return big[i] })]++
+	}
+	if counts[0] == 0 || counts[1] == 0 {
+		t.Errorf("huge equal weights drew %v, want both sides sampled", counts)
+	}
+}
@@ -0,0 +1,107 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+)
+
+// punctChain cycles a — b , a — b: every other token is
+// punctuation-only.
+func punctChain() *Chain {
+	c := NewChain(1)
+	c.addSuffix(startToken, "a", 1)
+	c.addSuffix("a", "—", 1)
+	c.addSuffix("—", "b", 1)
+	c.addSuffix("b", ",", 1)
+	c.addSuffix(",", "a", 1)
+	return c
+}
+
+func TestWordAccountingContract(t *testing.T) {
+	cases := []struct {
+		name      string
+		chain     *Chain
+		opts      GenerateOptions
+		wantWords int
+		wantPunct int // punctuation-only units expected in the output
+	}{
+		{
+			name:      "punct does not consume the budget",
+			chain:     punctChain(),
+			opts:      GenerateOptions{Words: 4, MaxSteps: 100},
+			wantWords: 7, // a — b , a — b: 4 counting words plus 3 punct units
+			wantPunct: 3,
+		},
+		{
+			name:      "count-punct makes every token count",
+			chain:     punctChain(),
+			opts:      GenerateOptions{Words: 4, CountPunct: true},
+			wantWords: 4, // a — b ,
+			wantPunct: 2,
+		},
+		{
+			name:      "plain words unaffected",
+			chain:     cyclicChain(),
+			opts:      GenerateOptions{Words: 5},
+			wantWords: 5,
+			wantPunct: 0,
+		},
+	}
+	for _, tc := range cases {
+		res := tc.chain.GenerateWithOptions(tc.opts)
+		units := strings.Fields(res.Text)
+		if res.Words != len(units) {
+			t.Errorf("%s: Words = %d but the output has %d units", tc.name, res.Words, len(units))
+		}
+		if res.Words != tc.wantWords {
+			t.Errorf("%s: Words = %d, want %d (text %q)", tc.name, res.Words, tc.wantWords, res.Text)
+		}
+		punct := 0
+		for _, u := range units {
+			if punctOnly(u) {
+				punct++
+			}
+		}
+		if punct != tc.wantPunct {
+			t.Errorf("%s: %d punctuation units, want %d (text %q)", tc.name, punct, tc.wantPunct, res.Text)
+		}
+		if err := tc.chain.checkWordAccounting(res); err != nil {
+			t.Errorf("%s: accounting check failed: %v", tc.name, err)
+		}
+	}
+}
+
+func TestSentinelSuffixNeverEmitted(t *testing.T) {
+	// A hand-edited model can list the sentinel as a suffix; the walk
+	// must cross it without letting it into the output or the count.
+	c := NewChain(1)
+	c.addSuffix(startToken, "a", 1)
+	c.addSuffix("a", startToken, 1)
+	res := c.GenerateWithOptions(GenerateOptions{Words: 3, MaxSteps: 50})
+	if strings.Contains(res.Text, startToken) {
+		t.Errorf("sentinel leaked into the output %q", res.Text)
+	}
+	for _, u := range strings.Fields(res.Text) {
+		if u != "a" {
+			t.Errorf("unexpected unit %q in %q", u, res.Text)
+		}
+	}
+}
+
+func TestPunctOnly(t *testing.T) {
+	for tok, want := range map[string]bool{
+		"—": true, "...": true, ",": true, "a": false, "3": false,
+		"word.": false, "½": false, "": false,
+	} {
+		if got := punctOnly(tok); got != want {
+			t.Errorf("punctOnly(%q) = %v, want %v", tok, got, want)
+		}
+	}
+}
+
+func TestGenerateStrictAccountingFlag(t *testing.T) {
+	model := writeTestModel(t)
+	if code, _, stderr := runCLI("generate", "-model", model, "-words", "10", "-strict"); code != 0 {
+		t.Errorf("generate -strict exited %d on a well-formed model: %s", code, stderr)
+	}
+}